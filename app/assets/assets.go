@@ -0,0 +1,84 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// hashLength is how many hex characters of the content hash go in a name
+const hashLength = 10
+
+// Manifest fingerprints every file in a directory, so a file's URL changes
+// whenever its content does and it can be cached for good
+type Manifest struct {
+	// hashed maps a file's name to its fingerprinted name, original the
+	// other way around
+	hashed map[string]string
+	original map[string]string
+}
+
+// NewManifest hashes every file in fsys
+func NewManifest(fsys fs.FS) (*Manifest, error) {
+	m := &Manifest{
+		hashed: map[string]string{},
+		original: map[string]string{},
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		hashed := fingerprint(name, hex.EncodeToString(sum[:])[:hashLength])
+
+		m.hashed[name] = hashed
+		m.original[hashed] = name
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("assets: building manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// fingerprint puts hash ahead of the extension, vendor/htmx.min.js becomes
+// vendor/htmx.min.<hash>.js
+func fingerprint(name string, hash string) string {
+	ext := path.Ext(name)
+
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Hashed returns the fingerprinted name of the file name, or name itself when
+// the manifest doesn't have it
+func (m *Manifest) Hashed(name string) string {
+	name = strings.TrimPrefix(name, "/")
+
+	if hashed, ok := m.hashed[name]; ok {
+		return hashed
+	}
+
+	return name
+}
+
+// Original returns the file a fingerprinted name stands for, reporting
+// whether it is one
+func (m *Manifest) Original(hashed string) (string, bool) {
+	name, ok := m.original[strings.TrimPrefix(hashed, "/")]
+
+	return name, ok
+}
@@ -1,45 +1,65 @@
 package auth
 
 import (
+	"bytes"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
+	"time"
+
+	"eatingisactivism/app/config"
+	"eatingisactivism/app/contentful"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
-var (
-	password string
+// maxWebhookBody is the largest webhook body read. Contentful sends a single
+// entry or asset, which is a few KiB.
+const maxWebhookBody = 1 << 20
+
+// Auth checks the site password and webhook signatures
+type Auth struct {
 	passwordHash string
 	salt string
-)
+	webhookSecret string
+}
 
-func init() {
-	godotenv.Load(".env")
+func New(cfg config.Config) *Auth {
+	a := &Auth{
+		salt: cfg.Salt,
+		// webhooks are signed with their own secret rather than the site
+		// password
+		webhookSecret: cfg.WebhookSecret,
+	}
 
-	password = os.Getenv("PASSWORD")
-	salt = os.Getenv("SALT")
+	a.passwordHash = a.HashValue(cfg.Password)
 
-	if (password == "" || salt == "") {
-		panic("PASSWORD or SALT not found in .env")
+	if a.webhookSecret == "" {
+		slog.Warn("CONTENTFUL_WEBHOOK_SECRET not found in .env, webhooks will be rejected")
 	}
 
-	passwordHash = HashValue(password)
+	return a
 }
 
-func HashValue(value string) string {
-	return fmt.Sprintf("%x", sha256.Sum256([]byte(value + salt)))
+func (a *Auth) HashValue(value string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(value + a.salt)))
 }
 
-func IsPasswordValid(token string) bool {
-	return token == passwordHash
+func (a *Auth) IsPasswordValid(token string) bool {
+	return token == a.passwordHash
 }
 
 func renderUnauthJSON(c *gin.Context, message string) {
-	c.JSON(http.StatusUnauthorized, gin.H{
+	renderErrorJSON(c, http.StatusUnauthorized, message)
+}
+
+func renderErrorJSON(c *gin.Context, status int, message string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{
 		"message": message,
 	})
 	c.Abort()
@@ -47,6 +67,7 @@ func renderUnauthJSON(c *gin.Context, message string) {
 }
 
 func renderUnauthHTML(c *gin.Context, message string) {
+		c.Header("Cache-Control", "no-store")
 		c.Redirect(http.StatusFound, "/login")
 		c.Abort()
 		return
@@ -73,13 +94,13 @@ func getToken(c *gin.Context) string {
 }
 
 
-func isAuthed(c *gin.Context) bool {
+func (a *Auth) isAuthed(c *gin.Context) bool {
 	token := getToken(c)
 
-	return IsPasswordValid(token)
+	return a.IsPasswordValid(token)
 }
 
-func AuthHTML() gin.HandlerFunc {
+func (a *Auth) AuthHTML() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if c.Request.URL.Path == "/login" {
 			c.Next()
@@ -96,10 +117,13 @@ func AuthHTML() gin.HandlerFunc {
 			return
 		}
 
-		if (isAuthed(c)) {
-			token := getToken(c)
+		if (a.isAuthed(c)) {
+			// only set the cookie when the token came some other way, a
+			// response setting it can't be cached at the edge
+			if cookie, _ := c.Cookie("_token"); cookie == "" {
+				c.SetCookie("_token", getToken(c), int(60 * 60 * 24), "/", "", false, true)
+			}
 
-			c.SetCookie("_token", token, int(60 * 60 * 24), "/", "", false, true)
 			c.Next()
 			return
 		}
@@ -108,13 +132,50 @@ func AuthHTML() gin.HandlerFunc {
 	}
 }
 
-func AuthJSON() gin.HandlerFunc {
+func (a *Auth) AuthJSON() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if (isAuthed(c)) {
+		if (a.isAuthed(c)) {
 			c.Next()
 			return
 		}
 
 		renderUnauthJSON(c, "Unauthorized")
 	}
-}
\ No newline at end of file
+}
+
+// AuthWebhook only lets through requests signed by Contentful with the
+// webhook secret. The body is read to check the signature and put back for
+// the handler, so it is capped at maxWebhookBody before anyone is verified.
+func (a *Auth) AuthWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.webhookSecret == "" {
+			renderUnauthJSON(c, "Webhooks are not configured")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+
+		var tooLarge *http.MaxBytesError
+
+		if errors.As(err, &tooLarge) {
+			renderErrorJSON(c, http.StatusRequestEntityTooLarge, "Request body is too large")
+			return
+		}
+
+		if err != nil {
+			renderErrorJSON(c, http.StatusBadRequest, "Error reading request body")
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		err = contentful.VerifyRequest(a.webhookSecret, c.Request, body, contentful.DefaultSignatureTTL, time.Now())
+
+		if err != nil {
+			renderUnauthJSON(c, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
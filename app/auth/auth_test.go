@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"eatingisactivism/app/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		secret string
+		body []byte
+		status int
+	}{
+		{name: "not configured", body: []byte("{}"), status: http.StatusUnauthorized},
+		{name: "unsigned", secret: "secret", body: []byte("{}"), status: http.StatusUnauthorized},
+		{name: "too large", secret: "secret", body: bytes.Repeat([]byte("a"), maxWebhookBody + 1), status: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := New(config.Config{WebhookSecret: test.secret})
+			handled := false
+
+			r := gin.New()
+			r.POST("/api/v1/webhook", a.AuthWebhook(), func(c *gin.Context) {
+				handled = true
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/webhook", bytes.NewReader(test.body)))
+
+			if w.Code != test.status {
+				t.Errorf("got status %d, want %d", w.Code, test.status)
+			}
+
+			if handled {
+				t.Error("handler ran for a request that wasn't verified")
+			}
+
+			if cacheControl := w.Header().Get("Cache-Control"); !strings.Contains(cacheControl, "no-store") {
+				t.Errorf("got Cache-Control %q, want no-store", cacheControl)
+			}
+		})
+	}
+}
+
+func TestAuthHTMLSetsCookieOnce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	a := New(config.Config{Password: "password", WebhookSecret: "secret"})
+	token := a.HashValue("password")
+
+	r := gin.New()
+	r.GET("/", a.AuthHTML(), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name string
+		request func(req *http.Request)
+		wantCookie bool
+	}{
+		{
+			name: "token in the query",
+			request: func(req *http.Request) {
+				req.URL.RawQuery = "_token=" + token
+			},
+			wantCookie: true,
+		},
+		{
+			name: "token in the cookie",
+			request: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: "_token", Value: token})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			test.request(req)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("got status %d", w.Code)
+			}
+
+			if setsCookie := w.Header().Get("Set-Cookie") != ""; setsCookie != test.wantCookie {
+				t.Errorf("set a cookie: %v, want %v", setsCookie, test.wantCookie)
+			}
+		})
+	}
+}
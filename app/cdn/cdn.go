@@ -0,0 +1,47 @@
+package cdn
+
+import (
+	"context"
+)
+
+// Cache tags the site's pages are labelled with, so every variant of a page
+// (query strings, locales) can be purged at once
+const (
+	// TagLocations is on every page and response that lists locations
+	TagLocations string = "locations"
+	// TagStandards and TagTags are on everything that shows their names
+	TagStandards string = "standards"
+	TagTags string = "tags"
+	// TagFoods is on everything built from the food data
+	TagFoods string = "foods"
+)
+
+// LocationTag is the cache tag on a single location's page
+func LocationTag(slug string) string {
+	return "location-" + slug
+}
+
+// Purger removes pages from the CDN cache so the next request sees fresh
+// content
+type Purger interface {
+	// PurgeURLs purges pages by URL. Paths are resolved against the site.
+	PurgeURLs(ctx context.Context, urls []string) error
+	// PurgeTags purges every page labelled with one of tags
+	PurgeTags(ctx context.Context, tags []string) error
+	PurgeEverything(ctx context.Context) error
+}
+
+// Noop is a Purger that does nothing, for running without a CDN
+type Noop struct{}
+
+func (Noop) PurgeURLs(ctx context.Context, urls []string) error {
+	return nil
+}
+
+func (Noop) PurgeTags(ctx context.Context, tags []string) error {
+	return nil
+}
+
+func (Noop) PurgeEverything(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,156 @@
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"eatingisactivism/app/config"
+)
+
+const (
+	// maxPurgeBatch is how many URLs or tags Cloudflare takes in one request
+	maxPurgeBatch = 30
+
+	cloudflareTimeout = 10 * time.Second
+)
+
+// Cloudflare purges through the Cloudflare API's purge_cache endpoint
+type Cloudflare struct {
+	token string
+	endpoint string
+	// site is what paths are resolved against, nil when there is no site URL
+	site *url.URL
+	client *http.Client
+}
+
+type cloudflareResponse struct {
+	Success bool `json:"success"`
+	Errors []struct {
+		Code int `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// NewCloudflare purges through cfg.CacheURL, resolving paths against
+// cfg.SiteURL. Without a site URL, purging URLs purges everything instead.
+func NewCloudflare(cfg config.Cloudflare) (*Cloudflare, error) {
+	var site *url.URL
+
+	if cfg.SiteURL == "" {
+		slog.Warn("SITE_URL is not set, purging pages will purge everything from Cloudflare")
+	} else {
+		var err error
+		site, err = url.Parse(cfg.SiteURL)
+
+		if err != nil || !site.IsAbs() {
+			return nil, fmt.Errorf("cdn: site URL %q is not an absolute URL", cfg.SiteURL)
+		}
+	}
+
+	return &Cloudflare{
+		token: cfg.Token,
+		endpoint: cfg.CacheURL,
+		site: site,
+		client: &http.Client{Timeout: cloudflareTimeout},
+	}, nil
+}
+
+func (c *Cloudflare) PurgeURLs(ctx context.Context, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	if c.site == nil {
+		return c.PurgeEverything(ctx)
+	}
+
+	files := make([]string, 0, len(urls))
+
+	for _, raw := range urls {
+		ref, err := url.Parse(raw)
+
+		if err != nil {
+			return fmt.Errorf("cdn: purging %q: %w", raw, err)
+		}
+
+		files = append(files, c.site.ResolveReference(ref).String())
+	}
+
+	return c.purgeBatches(ctx, "files", files)
+}
+
+func (c *Cloudflare) PurgeTags(ctx context.Context, tags []string) error {
+	return c.purgeBatches(ctx, "tags", tags)
+}
+
+func (c *Cloudflare) PurgeEverything(ctx context.Context) error {
+	return c.purge(ctx, map[string]any{"purge_everything": true})
+}
+
+// purgeBatches purges items under key, maxPurgeBatch at a time
+func (c *Cloudflare) purgeBatches(ctx context.Context, key string, items []string) error {
+	for len(items) > 0 {
+		n := min(len(items), maxPurgeBatch)
+		err := c.purge(ctx, map[string]any{key: items[:n]})
+
+		if err != nil {
+			return err
+		}
+
+		items = items[n:]
+	}
+
+	return nil
+}
+
+func (c *Cloudflare) purge(ctx context.Context, body map[string]any) error {
+	data, err := json.Marshal(body)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(data))
+
+	if err != nil {
+		return fmt.Errorf("cdn: building purge request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer " + c.token)
+
+	resp, err := c.client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("cdn: purging: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	var result cloudflareResponse
+
+	err = json.NewDecoder(resp.Body).Decode(&result)
+
+	if err != nil {
+		return fmt.Errorf("cdn: decoding purge response with status %d: %w", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode >= 300 || !result.Success {
+		messages := []string{}
+
+		for _, e := range result.Errors {
+			messages = append(messages, fmt.Sprintf("%d %s", e.Code, e.Message))
+		}
+
+		return fmt.Errorf("cdn: purge failed with status %d: %s", resp.StatusCode, strings.Join(messages, "; "))
+	}
+
+	return nil
+}
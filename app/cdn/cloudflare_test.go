@@ -0,0 +1,183 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"eatingisactivism/app/config"
+)
+
+// fakeCloudflare records the purge requests it was sent, answering with
+// response
+type fakeCloudflare struct {
+	status int
+	response string
+
+	mu sync.Mutex
+	requests []map[string][]string
+	bodies []string
+	tokens []string
+}
+
+func (f *fakeCloudflare) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, _ := io.ReadAll(r.Body)
+
+	var body map[string][]string
+
+	json.Unmarshal(data, &body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, body)
+	f.bodies = append(f.bodies, string(data))
+	f.tokens = append(f.tokens, r.Header.Get("Authorization"))
+	f.mu.Unlock()
+
+	w.WriteHeader(f.status)
+	io.WriteString(w, f.response)
+}
+
+func newTestCloudflare(t *testing.T, fake *fakeCloudflare) *Cloudflare {
+	t.Helper()
+
+	return newTestCloudflareAt(t, fake, "https://eatingisactivism.org")
+}
+
+func newTestCloudflareAt(t *testing.T, fake *fakeCloudflare, siteURL string) *Cloudflare {
+	t.Helper()
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	c, err := NewCloudflare(config.Cloudflare{
+		Token: "token",
+		CacheURL: server.URL,
+		SiteURL: siteURL,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestCloudflarePurgeURLsBatches(t *testing.T) {
+	fake := &fakeCloudflare{status: http.StatusOK, response: `{"success":true,"errors":[]}`}
+	c := newTestCloudflare(t, fake)
+
+	urls := []string{}
+
+	for i := 0; i < 2 * maxPurgeBatch + 5; i++ {
+		urls = append(urls, fmt.Sprintf("/locations/location-%d", i))
+	}
+
+	err := c.PurgeURLs(context.Background(), urls)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sizes := []int{}
+	purged := []string{}
+
+	for _, request := range fake.requests {
+		sizes = append(sizes, len(request["files"]))
+		purged = append(purged, request["files"]...)
+	}
+
+	if fmt.Sprint(sizes) != fmt.Sprint([]int{maxPurgeBatch, maxPurgeBatch, 5}) {
+		t.Errorf("sent batches of %v", sizes)
+	}
+
+	if len(purged) != len(urls) {
+		t.Fatalf("purged %d URLs, want %d", len(purged), len(urls))
+	}
+
+	for i, url := range purged {
+		if url != "https://eatingisactivism.org" + urls[i] {
+			t.Errorf("purged %s, want it resolved against the site URL", url)
+		}
+	}
+
+	for _, token := range fake.tokens {
+		if token != "Bearer token" {
+			t.Errorf("sent Authorization %q", token)
+		}
+	}
+}
+
+func TestCloudflarePurgeFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		status int
+		response string
+		want string
+	}{
+		{
+			name: "success false",
+			status: http.StatusOK,
+			response: `{"success":false,"errors":[{"code":1134,"message":"Unable to purge"}]}`,
+			want: "1134 Unable to purge",
+		},
+		{
+			name: "error status",
+			status: http.StatusForbidden,
+			response: `{"success":false,"errors":[{"code":10000,"message":"Authentication error"}]}`,
+			want: "status 403",
+		},
+		{
+			name: "not json",
+			status: http.StatusBadGateway,
+			response: "<html>bad gateway</html>",
+			want: "decoding purge response",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeCloudflare{status: test.status, response: test.response}
+			c := newTestCloudflare(t, fake)
+
+			tags := []string{}
+
+			for i := 0; i < maxPurgeBatch + 1; i++ {
+				tags = append(tags, LocationTag(fmt.Sprintf("location-%d", i)))
+			}
+
+			err := c.PurgeTags(context.Background(), tags)
+
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Fatalf("got %v, want an error mentioning %q", err, test.want)
+			}
+
+			// a failed batch stops the rest from being sent
+			if len(fake.requests) != 1 {
+				t.Errorf("sent %d requests after the first failed", len(fake.requests))
+			}
+		})
+	}
+}
+
+// a deployment without SITE_URL can't purge by URL, so it purges everything
+// rather than leaving pages stale
+func TestCloudflarePurgeURLsWithoutSiteURL(t *testing.T) {
+	fake := &fakeCloudflare{status: http.StatusOK, response: `{"success":true,"errors":[]}`}
+	c := newTestCloudflareAt(t, fake, "")
+
+	err := c.PurgeURLs(context.Background(), []string{"/locations/green-kitchen"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.bodies) != 1 || fake.bodies[0] != `{"purge_everything":true}` {
+		t.Errorf("sent %v, want a single purge of everything", fake.bodies)
+	}
+}
@@ -0,0 +1,79 @@
+package cdn
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Fake is a Purger that records what it was asked to purge, for tests. Err is
+// returned from every call when set.
+type Fake struct {
+	Err error
+
+	mu sync.Mutex
+	urls []string
+	tags []string
+	everything int
+}
+
+func (f *Fake) PurgeURLs(ctx context.Context, urls []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.urls = append(f.urls, urls...)
+
+	return f.Err
+}
+
+func (f *Fake) PurgeTags(ctx context.Context, tags []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tags = append(f.tags, tags...)
+
+	return f.Err
+}
+
+func (f *Fake) PurgeEverything(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.everything++
+
+	return f.Err
+}
+
+// URLs returns every URL purged so far
+func (f *Fake) URLs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.urls)
+}
+
+// Tags returns every tag purged so far
+func (f *Fake) Tags() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.tags)
+}
+
+// Everything returns how many times everything was purged
+func (f *Fake) Everything() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.everything
+}
+
+// Reset forgets everything recorded so far
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.urls = nil
+	f.tags = nil
+	f.everything = 0
+}
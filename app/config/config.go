@@ -0,0 +1,266 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	SourceContentful string = "contentful"
+	SourceFixtures string = "fixtures"
+)
+
+// Config is everything the app reads from the environment
+type Config struct {
+	Port string
+	// ShutdownTimeout is how long in flight requests, webhooks and refreshes
+	// get to finish on SIGTERM or SIGINT
+	ShutdownTimeout time.Duration
+	// Mode is GIN_MODE, "release" in production
+	Mode string
+	Password string
+	Salt string
+	MapboxToken string
+	// WebhookSecret signs Contentful webhooks, webhooks are rejected without
+	// one
+	WebhookSecret string
+	// DevAssets reads templates and static files from the working directory
+	// rather than the binary, so changes show up without a rebuild
+	DevAssets bool
+	// EdgeCache lets the CDN cache pages. Pages sit behind the site password
+	// while the CDN doesn't check it, so only turn it on when the CDN enforces
+	// access itself.
+	EdgeCache bool
+	Cloudflare Cloudflare
+	Locations Locations
+}
+
+type Cloudflare struct {
+	Token string
+	// CacheURL is the zone's purge_cache endpoint
+	CacheURL string
+	// SiteURL is the public URL of the site, pages are purged by URL under it.
+	// Without it purging a page purges everything.
+	SiteURL string
+}
+
+// Locations configures where locations come from and how often they are
+// refreshed
+type Locations struct {
+	// Source is SourceContentful or SourceFixtures
+	Source string
+	FixturesPath string
+	RefreshInterval time.Duration
+	RefreshJitter time.Duration
+	Contentful Contentful
+}
+
+type Contentful struct {
+	APIKey string
+	BaseURL string
+	SpaceID string
+	Environment string
+	// Locale is the default locale, Locales are the others to load
+	Locale string
+	Locales []string
+	Timeout time.Duration
+	Preview bool
+	PreviewAPIKey string
+	PreviewBaseURL string
+	// SnapshotPath is where the last good load is kept, empty turns it off
+	SnapshotPath string
+}
+
+func (cfg Config) Release() bool {
+	return cfg.Mode == "release"
+}
+
+// Load reads .env, when there is one, and then the environment
+func Load() (Config, error) {
+	godotenv.Load(".env")
+
+	return FromEnv(os.Getenv)
+}
+
+// FromEnv builds a config from getenv and validates it. Every problem found is
+// returned at once rather than only the first.
+func FromEnv(getenv func(string) string) (Config, error) {
+	env := reader{getenv: getenv}
+
+	cfg := Config{
+		Port: env.string("PORT", "8080"),
+		ShutdownTimeout: env.duration("SHUTDOWN_TIMEOUT", 20 * time.Second),
+		Mode: env.string("GIN_MODE", ""),
+		Password: env.string("PASSWORD", ""),
+		Salt: env.string("SALT", ""),
+		MapboxToken: env.string("MAPBOX_TOKEN", ""),
+		WebhookSecret: env.string("CONTENTFUL_WEBHOOK_SECRET", ""),
+		DevAssets: env.bool("DEV_ASSETS"),
+		EdgeCache: env.bool("EDGE_CACHE"),
+		Cloudflare: Cloudflare{
+			Token: env.string("CLOUDFLARE_TOKEN", ""),
+			CacheURL: env.string("CLOUDFLARE_CACHE_URL", ""),
+			SiteURL: env.string("SITE_URL", ""),
+		},
+		Locations: Locations{
+			Source: env.string("LOCATIONS_SOURCE", SourceContentful),
+			FixturesPath: env.string("LOCATIONS_FIXTURES", "fixtures/locations.yaml"),
+			RefreshInterval: env.duration("CONTENTFUL_REFRESH_INTERVAL", 5 * time.Minute),
+			RefreshJitter: env.duration("CONTENTFUL_REFRESH_JITTER", 30 * time.Second),
+			Contentful: Contentful{
+				APIKey: env.string("CONTENTFUL_API_KEY", ""),
+				BaseURL: env.string("CONTENTFUL_API_BASE_URL", ""),
+				SpaceID: env.string("CONTENTFUL_SPACE_ID", ""),
+				Environment: env.string("CONTENTFUL_ENVIRONMENT", "master"),
+				Locale: env.string("CONTENTFUL_LOCALE", "en-US"),
+				Locales: env.list("CONTENTFUL_LOCALES"),
+				Timeout: env.duration("CONTENTFUL_TIMEOUT", 30 * time.Second),
+				Preview: env.bool("CONTENTFUL_PREVIEW"),
+				PreviewAPIKey: env.string("CONTENTFUL_PREVIEW_API_KEY", ""),
+				PreviewBaseURL: env.string("CONTENTFUL_PREVIEW_API_BASE_URL", ""),
+				SnapshotPath: env.string("CONTENTFUL_SNAPSHOT_PATH", "data/contentful.json"),
+			},
+		},
+	}
+
+	if cfg.Locations.Contentful.SnapshotPath == "off" {
+		cfg.Locations.Contentful.SnapshotPath = ""
+	}
+
+	return cfg, errors.Join(append(env.errs, cfg.Validate())...)
+}
+
+// Validate reports every setting that is missing or doesn't make sense
+func (cfg Config) Validate() error {
+	errs := []error{}
+
+	if cfg.Password == "" || cfg.Salt == "" {
+		errs = append(errs, errors.New("PASSWORD and SALT are required"))
+	}
+
+	switch cfg.Locations.Source {
+		case SourceContentful:
+			errs = append(errs, cfg.Locations.Contentful.validate())
+		case SourceFixtures:
+			if cfg.Locations.FixturesPath == "" {
+				errs = append(errs, errors.New("LOCATIONS_FIXTURES is required when LOCATIONS_SOURCE is fixtures"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("LOCATIONS_SOURCE is %q, want %q or %q", cfg.Locations.Source, SourceContentful, SourceFixtures))
+	}
+
+	if cfg.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+
+	if cfg.Locations.RefreshInterval < 0 || cfg.Locations.RefreshJitter < 0 {
+		errs = append(errs, errors.New("CONTENTFUL_REFRESH_INTERVAL and CONTENTFUL_REFRESH_JITTER can't be negative"))
+	}
+
+	if (cfg.Cloudflare.Token == "") != (cfg.Cloudflare.CacheURL == "") {
+		errs = append(errs, errors.New("CLOUDFLARE_TOKEN and CLOUDFLARE_CACHE_URL must be set together"))
+	}
+
+	// without SITE_URL pages can't be purged by URL, the CDN falls back to
+	// purging everything
+	if cfg.Cloudflare.SiteURL != "" {
+		site, err := url.Parse(cfg.Cloudflare.SiteURL)
+
+		if err != nil || !site.IsAbs() {
+			errs = append(errs, fmt.Errorf("SITE_URL is %q, want an absolute URL like https://example.com", cfg.Cloudflare.SiteURL))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (cfg Contentful) validate() error {
+	errs := []error{}
+
+	if cfg.APIKey == "" || cfg.BaseURL == "" || cfg.SpaceID == "" {
+		errs = append(errs, errors.New("CONTENTFUL_API_KEY, CONTENTFUL_API_BASE_URL and CONTENTFUL_SPACE_ID are required, set LOCATIONS_SOURCE=fixtures to run without Contentful"))
+	}
+
+	if cfg.Preview && cfg.PreviewAPIKey == "" {
+		errs = append(errs, errors.New("CONTENTFUL_PREVIEW_API_KEY is required when CONTENTFUL_PREVIEW is set"))
+	}
+
+	if cfg.Timeout <= 0 {
+		errs = append(errs, errors.New("CONTENTFUL_TIMEOUT must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// reader reads typed values from the environment, collecting the ones that
+// don't parse
+type reader struct {
+	getenv func(string) string
+	errs []error
+}
+
+func (r *reader) string(key string, def string) string {
+	value := strings.TrimSpace(r.getenv(key))
+
+	if value == "" {
+		return def
+	}
+
+	return value
+}
+
+func (r *reader) duration(key string, def time.Duration) time.Duration {
+	value := r.string(key, "")
+
+	if value == "" {
+		return def
+	}
+
+	duration, err := time.ParseDuration(value)
+
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s is %q, want a duration like 5m", key, value))
+		return def
+	}
+
+	return duration
+}
+
+func (r *reader) bool(key string) bool {
+	value := r.string(key, "")
+
+	if value == "" {
+		return false
+	}
+
+	parsed, err := strconv.ParseBool(value)
+
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("%s is %q, want true or false", key, value))
+		return false
+	}
+
+	return parsed
+}
+
+// list reads a comma separated list, leaving out empty items
+func (r *reader) list(key string) []string {
+	items := []string{}
+
+	for _, item := range strings.Split(r.getenv(key), ",") {
+		item = strings.TrimSpace(item)
+
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+)
+
+func testEnv(values map[string]string) func(string) string {
+	env := map[string]string{
+		"PASSWORD": "password",
+		"SALT": "salt",
+		"LOCATIONS_SOURCE": SourceFixtures,
+		"LOCATIONS_FIXTURES": "fixtures.json",
+	}
+
+	for key, value := range values {
+		env[key] = value
+	}
+
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestCloudflareSiteURL(t *testing.T) {
+	tests := []struct {
+		name string
+		env map[string]string
+		valid bool
+	}{
+		{
+			name: "without SITE_URL",
+			env: map[string]string{"CLOUDFLARE_TOKEN": "token", "CLOUDFLARE_CACHE_URL": "https://api.cloudflare.com/purge"},
+			valid: true,
+		},
+		{
+			name: "with SITE_URL",
+			env: map[string]string{"CLOUDFLARE_TOKEN": "token", "CLOUDFLARE_CACHE_URL": "https://api.cloudflare.com/purge", "SITE_URL": "https://eatingisactivism.org"},
+			valid: true,
+		},
+		{
+			name: "relative SITE_URL",
+			env: map[string]string{"CLOUDFLARE_TOKEN": "token", "CLOUDFLARE_CACHE_URL": "https://api.cloudflare.com/purge", "SITE_URL": "eatingisactivism.org"},
+		},
+		{
+			name: "token without cache URL",
+			env: map[string]string{"CLOUDFLARE_TOKEN": "token"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := FromEnv(testEnv(test.env))
+
+			if (err == nil) != test.valid {
+				t.Errorf("got %v, want valid: %v", err, test.valid)
+			}
+		})
+	}
+}
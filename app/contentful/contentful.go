@@ -1,11 +1,15 @@
 package contentful
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"encoding/json"
-	// "html/template"
+	"time"
 )
 
 type Contentful struct {
@@ -14,8 +18,29 @@ type Contentful struct {
 	BaseURL string
 	SpaceID string
 	Environment string
+	Locale string
+
+	preview bool
+	timeout time.Duration
+	userAgent string
+	maxRetries int
+	retryBackoff time.Duration
 }
 
+// DefaultLocale is the locale entries and assets are requested in unless
+// WithLocale or ForLocale says otherwise
+const DefaultLocale string = "en-US"
+
+const (
+	// DefaultMaxRetries is how many times a rate limited or failed request is
+	// retried before giving up
+	DefaultMaxRetries int = 5
+
+	defaultRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff = 30 * time.Second
+	maxErrorBody = 64 << 10
+)
+
 type ContentfulResponseLink struct {
 	Sys struct {
 		Type string `json:"type"`
@@ -40,6 +65,8 @@ type ContentfulLocation struct {
 		} `json:"coordinates"`
 		Standard ContentfulResponseLink `json:"standard"`
 		Tags []ContentfulResponseLink `json:"tags"`
+		HeroImage ContentfulResponseLink `json:"heroImage"`
+		Gallery []ContentfulResponseLink `json:"gallery"`
 	} `json:"fields"`
 }
 
@@ -65,6 +92,28 @@ type ContentfulLocationTag struct {
 	} `json:"fields"`
 }
 
+type ContentfulAsset struct {
+	Sys struct {
+		ID string `json:"id"`
+	} `json:"sys"`
+	Fields struct {
+		Title string `json:"title"`
+		Description string `json:"description"`
+		File struct {
+			URL string `json:"url"`
+			FileName string `json:"fileName"`
+			ContentType string `json:"contentType"`
+			Details struct {
+				Size int `json:"size"`
+				Image struct {
+					Width int `json:"width"`
+					Height int `json:"height"`
+				} `json:"image"`
+			} `json:"details"`
+		} `json:"file"`
+	} `json:"fields"`
+}
+
 type ContentfulLocationResponse struct {
 	Items []ContentfulLocation `json:"items"`
 	Message string `json:"message"`
@@ -83,6 +132,7 @@ type ContentfulLocationTagResponse struct {
 type ContentfulWebhook struct {
 	Sys struct {
 		ID string `json:"id"`
+		Type string `json:"type"`
 		ContentType struct {
 			Sys struct {
 				ID string `json:"id"`
@@ -97,31 +147,83 @@ const (
 	WebhookArchive string = "ContentManagement.Entry.archive"
 	WebhookUnarchive string = "ContentManagement.Entry.unarchive"
 	WebhookDelete string = "ContentManagement.Entry.delete"
+	// WebhookSave and WebhookAutoSave fire as editors change a draft, they
+	// only matter to a preview
+	WebhookSave string = "ContentManagement.Entry.save"
+	WebhookAutoSave string = "ContentManagement.Entry.auto_save"
+	WebhookAssetPublish string = "ContentManagement.Asset.publish"
+	WebhookAssetUnpublish string = "ContentManagement.Asset.unpublish"
+	WebhookAssetArchive string = "ContentManagement.Asset.archive"
+	WebhookAssetUnarchive string = "ContentManagement.Asset.unarchive"
+	WebhookAssetDelete string = "ContentManagement.Asset.delete"
 )
 
-func New(token string, spaceId string, environment string, baseURL string) *Contentful {
-	return &Contentful{
+// New returns a client for the Delivery API at baseURL. The token is sent in
+// the Authorization header, never in the URL where it would end up in logs.
+func New(token string, spaceId string, baseURL string, opts ...Option) *Contentful {
+	c := &Contentful{
 		client: &http.Client{},
 		token: token,
 		BaseURL: baseURL,
 		SpaceID: spaceId,
-		Environment: environment,
+		Environment: DefaultEnvironment,
+		Locale: DefaultLocale,
+		timeout: DefaultTimeout,
+		userAgent: DefaultUserAgent,
+		maxRetries: DefaultMaxRetries,
+		retryBackoff: defaultRetryBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	if c.timeout > 0 && c.client.Timeout != c.timeout {
+		client := *c.client
+		client.Timeout = c.timeout
+		c.client = &client
+	}
+
+	return c
+}
+
+// NewPreview returns a client for the Preview API, which serves drafts as
+// well as published entries
+func NewPreview(token string, spaceId string, opts ...Option) *Contentful {
+	return New(token, spaceId, PreviewBaseURL, append([]Option{WithPreview()}, opts...)...)
+}
+
+// Preview reports whether the client serves drafts
+func (c *Contentful) Preview() bool {
+	return c.preview
+}
+
+// ForLocale returns a copy of the client that fetches entries and assets in
+// locale. Contentful fills fields missing in locale from its fallback locale.
+func (c *Contentful) ForLocale(locale string) *Contentful {
+	localized := *c
+	localized.Locale = locale
+
+	return &localized
+}
+
+func (c *Contentful) environmentURL() string {
+	return fmt.Sprintf("%s/spaces/%s/environments/%s", c.BaseURL, c.SpaceID, c.Environment)
 }
 
 func (c *Contentful) getEntriesURL(contentType string, id string) (string, error) {
-	url := fmt.Sprintf("%s/spaces/%s/environments/%s/entries", c.BaseURL, c.SpaceID, c.Environment)
+	url := fmt.Sprintf("%s/entries", c.environmentURL())
 
 	if id != "" {
 		url = fmt.Sprintf("%s/%s", url, id)
 	}
 
-	url = fmt.Sprintf("%s?access_token=%s&content_type=%s", url, c.token, contentType)
+	url = fmt.Sprintf("%s?content_type=%s&locale=%s", url, contentType, c.Locale)
 
 	return url, nil
 }
 
-func (c *Contentful) GetEntries(contentType string, limit int, offset int, id string) ([]byte, error) {
+func (c *Contentful) GetEntries(ctx context.Context, contentType string, limit int, offset int, id string) ([]byte, error) {
 	url, err := c.getEntriesURL(contentType, id)
 
 	if err != nil {
@@ -137,138 +239,114 @@ func (c *Contentful) GetEntries(contentType string, limit int, offset int, id st
 		url = fmt.Sprintf("%s&order=sys.createdAt", url)
 	}
 
-	resp, err := c.client.Get(url)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// if the status code is 429, we are being rate limited
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, fmt.Errorf("error: rate limited")
-	}
+	return c.get(ctx, url)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error: %d", resp.StatusCode)
-	}
+// GetAsset fetches a single published asset
+func (c *Contentful) GetAsset(ctx context.Context, id string) ([]byte, error) {
+	url := fmt.Sprintf("%s/assets/%s?locale=%s", c.environmentURL(), id, c.Locale)
 
-	if resp == nil {
-		return nil, fmt.Errorf("error: no response")
-	}
+	return c.get(ctx, url)
+}
 
-	defer resp.Body.Close()
-	resBody, err := io.ReadAll(resp.Body)
+// get fetches url, retrying rate limited and 5xx responses with exponential
+// backoff. A rate limited response waits at least as long as Contentful asked
+// for. Retries stop once ctx is done or the wait would run past its deadline,
+// returning the last error.
+func (c *Contentful) get(ctx context.Context, url string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		body, err := c.do(ctx, url)
 
-	if err != nil {
-		return nil, err
-	}
+		if err == nil {
+			return body, nil
+		}
 
-	return resBody, nil
-}
+		if attempt >= c.maxRetries || !retryable(err) {
+			return nil, err
+		}
 
+		wait := c.backoff(attempt, err)
 
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, err
+		}
 
-type NodeData map[string]interface{}
+		timer := time.NewTimer(wait)
 
-type Node struct {
-	NodeType string `json:"nodeType"`
-	Data NodeData `json:"data"`
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
 }
 
-type Mark struct {
-	Type string `json:"type"`
-}
+// do makes a single request. The body is always closed, and error responses
+// are read for the message Contentful sends back.
+func (c *Contentful) do(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 
-type Text struct {
-	*Node
-	Value string `json:"value"`
-	Marks []Mark `json:"marks"`
-}
+	if err != nil {
+		return nil, err
+	}
 
-type Inline struct {
-	*Node
-	Content []interface{} `json:"content"`
-}
+	req.Header.Set("Authorization", "Bearer " + c.token)
+	req.Header.Set("User-Agent", c.userAgent)
 
-type Block struct {
-	*Node
-	Content []interface{} `json:"content"`
-}
+	resp, err := c.client.Do(req)
 
+	if err != nil {
+		return nil, err
+	}
 
-// func RichTextToHTMLString(data json.RawMessage) template.HTML {
-// 	var document []interface{}
+	defer resp.Body.Close()
 
-// 	err := json.Unmarshal(data, &document)
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// drain what's left so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
 
-// 	if err != nil {
-// 		return ""
-// 	}
+		err := &RateLimitError{
+			RetryAfter: retryAfter(resp),
+		}
 
-// 	if (document == nil) {
-// 		return ""
-// 	}
+		slog.Warn("contentful rate limited", "path", req.URL.Path, "retryAfter", err.RetryAfter)
 
-// 	if (len(document.content) == 0) {
-// 		return ""
-// 	}
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := statusError(resp)
 
-// 	return template.HTML(output)
-// }
+		slog.Warn("contentful request failed", "path", req.URL.Path, "status", resp.StatusCode, "requestID", err.RequestID)
 
-// func NodeListToString(data []interface{}) (string, error) {
+		return nil, err
+	}
 
-// }
+	return io.ReadAll(resp.Body)
+}
 
-func NodeToString(data interface{}) (string, error) {
-	var content map[string]interface{}
+// backoff returns how long to wait before retrying after attempt failed with
+// err. The wait doubles each attempt up to maxRetryBackoff, with up to half
+// of it again added at random so clients don't retry in lockstep.
+func (c *Contentful) backoff(attempt int, err error) time.Duration {
+	wait := c.retryBackoff
 
-	// err := json.Unmarshal(data, &content)
+	for i := 0; i < attempt && wait < maxRetryBackoff; i++ {
+		wait *= 2
+	}
 
-	// if err != nil {
-	// 	return "", err
-	// }
+	wait = min(wait, maxRetryBackoff)
 
-	if (content == nil) {
-		return "", nil
+	if wait > 0 {
+		wait += rand.N(wait / 2 + 1)
 	}
 
-	switch content["nodeType"] {
-		case "document":
-		case "paragraph":
-		case "heading-1":
-		case "heading-2":
-		case "heading-3":
-		case "heading-4":
-		case "heading-5":
-		case "heading-6":
-		case "ordered-list":
-		case "unordered-list":
-		case "list-item":
-		case "hr":
-		case "blockquote":
-		case "embedded-entry-block":
-		case "embedded-asset-block":
-		case "embedded-resource-block":
-		case "table":
-		case "table-row":
-		case "table-cell":
-		case "table-header-cell":
-
-		case "hyperlink":
-		case "entry-hyperlink":
-		case "asset-hyperlink":
-		case "resource-hyperlink":
-		case "embedded-entry-inline":
-		case "embedded-resource-inline":
-
-		case "bold":
-		case "italic":
-		case "underline":
-		case "code":
-		case "superscript":
-		case "subscript":
+	var rateLimit *RateLimitError
+
+	if errors.As(err, &rateLimit) {
+		wait = max(wait, rateLimit.RetryAfter)
 	}
 
-	return fmt.Sprintf("%s", content), nil
+	return wait
 }
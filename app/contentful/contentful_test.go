@@ -0,0 +1,288 @@
+package contentful
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeResponse struct {
+	status int
+	reset int
+	body string
+}
+
+// fakeAPI answers with responses in order, repeating the last one once it runs
+// out
+type fakeAPI struct {
+	responses []fakeResponse
+
+	mu sync.Mutex
+	requests int
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	response := f.responses[min(f.requests, len(f.responses) - 1)]
+	f.requests++
+	f.mu.Unlock()
+
+	if response.status == http.StatusTooManyRequests {
+		w.Header().Set("X-Contentful-RateLimit-Reset", strconv.Itoa(response.reset))
+	}
+
+	w.WriteHeader(response.status)
+	io.WriteString(w, response.body)
+}
+
+func (f *fakeAPI) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.requests
+}
+
+// closeTracker counts the response bodies handed out and closed
+type closeTracker struct {
+	opened atomic.Int32
+	closed atomic.Int32
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	tracker *closeTracker
+}
+
+func (b *trackedBody) Close() error {
+	b.tracker.closed.Add(1)
+	return b.ReadCloser.Close()
+}
+
+func (t *closeTracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.opened.Add(1)
+	resp.Body = &trackedBody{ReadCloser: resp.Body, tracker: t}
+
+	return resp, nil
+}
+
+// newTestClient points a client at a fake API with backoff short enough for
+// tests, and checks every response body was closed once the test is done
+func newTestClient(t *testing.T, responses []fakeResponse, opts ...Option) (*Contentful, *fakeAPI) {
+	t.Helper()
+
+	fake := &fakeAPI{responses: responses}
+	server := httptest.NewServer(fake)
+	tracker := &closeTracker{}
+
+	t.Cleanup(func() {
+		server.Close()
+
+		if tracker.opened.Load() != tracker.closed.Load() {
+			t.Errorf("closed %d of %d response bodies", tracker.closed.Load(), tracker.opened.Load())
+		}
+	})
+
+	opts = append([]Option{WithHTTPClient(&http.Client{Transport: tracker})}, opts...)
+	c := New("token", "space", server.URL, opts...)
+	c.retryBackoff = time.Millisecond
+
+	return c, fake
+}
+
+func TestGetHonoursRateLimitReset(t *testing.T) {
+	c, fake := newTestClient(t, []fakeResponse{
+		{status: http.StatusTooManyRequests, reset: 1},
+		{status: http.StatusOK, body: `{"items":[]}`},
+	})
+
+	start := time.Now()
+	body, err := c.get(context.Background(), c.BaseURL + "/entries")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != `{"items":[]}` {
+		t.Errorf("got body %q", body)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, before the reset of 1s", elapsed)
+	}
+
+	if fake.count() != 2 {
+		t.Errorf("made %d requests, want 2", fake.count())
+	}
+}
+
+func TestGetRetriesServerErrors(t *testing.T) {
+	t.Run("until it succeeds", func(t *testing.T) {
+		c, fake := newTestClient(t, []fakeResponse{
+			{status: http.StatusInternalServerError},
+			{status: http.StatusBadGateway, body: "<html>bad gateway</html>"},
+			{status: http.StatusOK, body: "{}"},
+		})
+
+		_, err := c.get(context.Background(), c.BaseURL + "/entries")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fake.count() != 3 {
+			t.Errorf("made %d requests, want 3", fake.count())
+		}
+	})
+
+	t.Run("up to maxRetries", func(t *testing.T) {
+		c, fake := newTestClient(t, []fakeResponse{
+			{status: http.StatusServiceUnavailable, body: `{"message":"down for maintenance","requestId":"abc"}`},
+		}, WithMaxRetries(3))
+
+		_, err := c.get(context.Background(), c.BaseURL + "/entries")
+
+		var status *StatusError
+
+		if !errors.As(err, &status) || status.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("got %v, want a 503 StatusError", err)
+		}
+
+		if status.Message != "down for maintenance" || status.RequestID != "abc" {
+			t.Errorf("got message %q and request ID %q", status.Message, status.RequestID)
+		}
+
+		if fake.count() != 4 {
+			t.Errorf("made %d requests, want 4", fake.count())
+		}
+	})
+}
+
+func TestGetDoesNotRetryClientErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		want error
+	}{
+		{status: http.StatusNotFound, want: ErrNotFound},
+		{status: http.StatusUnauthorized, want: ErrUnauthorized},
+		{status: http.StatusForbidden, want: ErrUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			c, fake := newTestClient(t, []fakeResponse{
+				{status: test.status, body: `{"message":"nope"}`},
+			})
+
+			_, err := c.get(context.Background(), c.BaseURL + "/entries")
+
+			if !errors.Is(err, test.want) {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+
+			if fake.count() != 1 {
+				t.Errorf("made %d requests, want 1", fake.count())
+			}
+		})
+	}
+}
+
+func TestGetStopsAtDeadline(t *testing.T) {
+	c, fake := newTestClient(t, []fakeResponse{
+		{status: http.StatusTooManyRequests, reset: 60},
+		{status: http.StatusOK, body: "{}"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5 * time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.get(ctx, c.BaseURL + "/entries")
+
+	var rateLimit *RateLimitError
+
+	if !errors.As(err, &rateLimit) || rateLimit.RetryAfter != time.Minute {
+		t.Fatalf("got %v, want a RateLimitError to retry after 1m", err)
+	}
+
+	// waiting would run past the deadline, so it gives up straight away
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %s", elapsed)
+	}
+
+	if fake.count() != 1 {
+		t.Errorf("made %d requests, want 1", fake.count())
+	}
+}
+
+func TestGetStopsWhenCancelled(t *testing.T) {
+	c, fake := newTestClient(t, []fakeResponse{
+		{status: http.StatusTooManyRequests, reset: 60},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50 * time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := c.get(ctx, c.BaseURL + "/entries")
+
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("got %v, want ErrRateLimited", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned %s after being cancelled", elapsed)
+	}
+
+	if fake.count() != 1 {
+		t.Errorf("made %d requests, want 1", fake.count())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	c := New("token", "space", "http://contentful.invalid")
+	c.retryBackoff = 100 * time.Millisecond
+
+	tests := []struct {
+		name string
+		attempt int
+		err error
+		least time.Duration
+		most time.Duration
+	}{
+		{name: "first retry", attempt: 0, least: 100 * time.Millisecond, most: 150 * time.Millisecond},
+		{name: "doubles", attempt: 2, least: 400 * time.Millisecond, most: 600 * time.Millisecond},
+		{name: "capped", attempt: 20, least: maxRetryBackoff, most: maxRetryBackoff * 3 / 2},
+		{name: "rate limit reset", attempt: 0, err: &RateLimitError{RetryAfter: 10 * time.Second}, least: 10 * time.Second, most: 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.err
+
+			if err == nil {
+				err = &StatusError{StatusCode: http.StatusServiceUnavailable}
+			}
+
+			for i := 0; i < 100; i++ {
+				wait := c.backoff(test.attempt, err)
+
+				if wait < test.least || wait > test.most {
+					t.Fatalf("waited %s, want between %s and %s", wait, test.least, test.most)
+				}
+			}
+		})
+	}
+}
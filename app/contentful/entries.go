@@ -0,0 +1,197 @@
+package contentful
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// MaxPageSize is the most entries the Delivery API returns in one request
+const MaxPageSize int = 1000
+
+// EntryCollection is a single page from the entries endpoint
+type EntryCollection struct {
+	Total int `json:"total"`
+	Skip int `json:"skip"`
+	Limit int `json:"limit"`
+	Items []json.RawMessage `json:"items"`
+	Includes Includes `json:"includes"`
+	Message string `json:"message"`
+}
+
+// EntryIterator pages through every entry of a content type. Entries are
+// ordered by creation date and then ID, so entries don't move between pages
+// unless one is published or deleted mid way.
+type EntryIterator struct {
+	client *Contentful
+	contentType string
+	limit int
+	include int
+	// fields limits the fields of each entry through select, empty returns
+	// them all
+	fields string
+	skip int
+	total int
+	done bool
+}
+
+// Entries returns an iterator over all entries of contentType, fetching
+// limit entries per request. A limit outside 1 to MaxPageSize uses
+// MaxPageSize. include is how many levels of links each page should bring
+// along in its includes.
+func (c *Contentful) Entries(contentType string, limit int, include int) *EntryIterator {
+	if limit <= 0 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	return &EntryIterator{
+		client: c,
+		contentType: contentType,
+		limit: limit,
+		include: include,
+	}
+}
+
+// Next fetches the next page. It returns a nil page once every entry has
+// been read.
+func (it *EntryIterator) Next(ctx context.Context) (*EntryCollection, error) {
+	if it.done {
+		return nil, nil
+	}
+
+	url, err := it.client.getEntriesURL(it.contentType, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	url = fmt.Sprintf("%s&limit=%d&skip=%d&order=sys.createdAt,sys.id&include=%d", url, it.limit, it.skip, it.include)
+
+	if it.fields != "" {
+		url = fmt.Sprintf("%s&select=%s", url, it.fields)
+	}
+
+	body, err := it.client.get(ctx, url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var page EntryCollection
+
+	err = json.Unmarshal(body, &page)
+
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+
+	it.total = page.Total
+	it.skip = page.Skip + len(page.Items)
+
+	if len(page.Items) == 0 || it.skip >= it.total {
+		it.done = true
+	}
+
+	return &page, nil
+}
+
+// Total is the number of entries reported by the last page
+func (it *EntryIterator) Total() int {
+	return it.total
+}
+
+// GetAllEntries fetches every entry of contentType, however many pages that
+// takes, along with a graph of everything linked from them up to include
+// levels deep
+func (c *Contentful) GetAllEntries(ctx context.Context, contentType string, include int) ([]json.RawMessage, *LinkGraph, error) {
+	it := c.Entries(contentType, MaxPageSize, include)
+	items := []json.RawMessage{}
+	graph := NewLinkGraph()
+
+	for {
+		page, err := it.Next(ctx)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if page == nil {
+			return items, graph, nil
+		}
+
+		items = append(items, page.Items...)
+		graph.Add(page)
+	}
+}
+
+// EntryIDs returns the ID of every entry of contentType. Only sys.id is
+// requested, so it is much cheaper than fetching the entries.
+func (c *Contentful) EntryIDs(ctx context.Context, contentType string) ([]string, error) {
+	it := c.Entries(contentType, MaxPageSize, 0)
+	it.fields = "sys.id"
+	ids := []string{}
+
+	for {
+		page, err := it.Next(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if page == nil {
+			return ids, nil
+		}
+
+		for _, item := range page.Items {
+			var entry struct {
+				Sys struct {
+					ID string `json:"id"`
+				} `json:"sys"`
+			}
+
+			err = json.Unmarshal(item, &entry)
+
+			if err != nil {
+				return nil, &DecodeError{Err: err}
+			}
+
+			ids = append(ids, entry.Sys.ID)
+		}
+	}
+}
+
+// GetEntry fetches a single entry with everything linked from it up to
+// include levels deep. The entries/:id endpoint doesn't support include, so
+// this queries the collection by sys.id instead.
+func (c *Contentful) GetEntry(ctx context.Context, contentType string, id string, include int) (json.RawMessage, *LinkGraph, error) {
+	url, err := c.getEntriesURL(contentType, "")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	url = fmt.Sprintf("%s&sys.id=%s&include=%d", url, id, include)
+
+	body, err := c.get(ctx, url)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var collection EntryCollection
+
+	err = json.Unmarshal(body, &collection)
+
+	if err != nil {
+		return nil, nil, &DecodeError{Err: err}
+	}
+
+	if len(collection.Items) == 0 {
+		return nil, nil, NotFoundError(contentType, id)
+	}
+
+	graph := NewLinkGraph()
+	graph.Add(&collection)
+
+	return collection.Items[0], graph, nil
+}
@@ -0,0 +1,168 @@
+package contentful
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakeEntries is a Delivery API that pages through total entries the way
+// Contentful does, recording the queries it was sent
+type fakeEntries struct {
+	total int
+
+	mu sync.Mutex
+	skips []int
+	orders []string
+}
+
+func (f *fakeEntries) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	skip, _ := strconv.Atoi(query.Get("skip"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	f.mu.Lock()
+	f.skips = append(f.skips, skip)
+	f.orders = append(f.orders, query.Get("order"))
+	f.mu.Unlock()
+
+	items := []json.RawMessage{}
+
+	for i := skip; i < min(skip + limit, f.total); i++ {
+		items = append(items, json.RawMessage(fmt.Sprintf(`{"sys":{"id":"entry-%d"}}`, i)))
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"total": f.total,
+		"skip": skip,
+		"limit": limit,
+		"items": items,
+	})
+}
+
+func entryIDs(t *testing.T, items []json.RawMessage) []string {
+	t.Helper()
+
+	ids := []string{}
+
+	for _, item := range items {
+		var entry struct {
+			Sys struct {
+				ID string `json:"id"`
+			} `json:"sys"`
+		}
+
+		err := json.Unmarshal(item, &entry)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids = append(ids, entry.Sys.ID)
+	}
+
+	return ids
+}
+
+func TestEntryIteratorPages(t *testing.T) {
+	tests := []struct {
+		name string
+		total int
+		limit int
+		skips []int
+	}{
+		{name: "empty collection", total: 0, limit: 2, skips: []int{0}},
+		{name: "single short page", total: 1, limit: 2, skips: []int{0}},
+		{name: "exact pages", total: 4, limit: 2, skips: []int{0, 2}},
+		{name: "final short page", total: 5, limit: 2, skips: []int{0, 2, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := &fakeEntries{total: test.total}
+			server := httptest.NewServer(fake)
+			defer server.Close()
+
+			client := New("token", "space", server.URL)
+			it := client.Entries("location", test.limit, 0)
+			items := []json.RawMessage{}
+
+			for {
+				page, err := it.Next(context.Background())
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if page == nil {
+					break
+				}
+
+				items = append(items, page.Items...)
+			}
+
+			seen := map[string]bool{}
+
+			for _, id := range entryIDs(t, items) {
+				if seen[id] {
+					t.Errorf("entry %s came back twice", id)
+				}
+
+				seen[id] = true
+			}
+
+			if len(seen) != test.total {
+				t.Errorf("got %d entries, want %d", len(seen), test.total)
+			}
+
+			if fmt.Sprint(fake.skips) != fmt.Sprint(test.skips) {
+				t.Errorf("skips were %v, want %v", fake.skips, test.skips)
+			}
+
+			for _, order := range fake.orders {
+				if order != "sys.createdAt,sys.id" {
+					t.Errorf("order was %q, want sys.createdAt,sys.id", order)
+				}
+			}
+		})
+	}
+}
+
+func TestGetAllEntries(t *testing.T) {
+	fake := &fakeEntries{total: 2 * MaxPageSize + 5}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	items, graph, err := New("token", "space", server.URL).GetAllEntries(context.Background(), "location", 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if graph == nil {
+		t.Fatal("got no link graph")
+	}
+
+	ids := entryIDs(t, items)
+
+	if len(ids) != fake.total {
+		t.Fatalf("got %d entries, want %d", len(ids), fake.total)
+	}
+
+	for i, id := range ids {
+		if id != fmt.Sprintf("entry-%d", i) {
+			t.Fatalf("entry %d is %s, entries came back out of order or twice", i, id)
+		}
+	}
+
+	want := []int{0, MaxPageSize, 2 * MaxPageSize}
+
+	if fmt.Sprint(fake.skips) != fmt.Sprint(want) {
+		t.Errorf("skips were %v, want %v", fake.skips, want)
+	}
+}
@@ -0,0 +1,130 @@
+package contentful
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("contentful: not found")
+	ErrUnauthorized = errors.New("contentful: unauthorized")
+	ErrRateLimited = errors.New("contentful: rate limited")
+)
+
+// RateLimitError is returned for a 429. RetryAfter is how long Contentful
+// asked us to wait, zero when it didn't say.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter == 0 {
+		return ErrRateLimited.Error()
+	}
+
+	return fmt.Sprintf("%s, retry after %s", ErrRateLimited, e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// StatusError is returned for any other response that isn't a 200. It matches
+// ErrNotFound and ErrUnauthorized with errors.Is where the status says so.
+type StatusError struct {
+	StatusCode int
+	// Message is the message Contentful sent back, if any
+	Message string
+	RequestID string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("contentful: unexpected status %d", e.StatusCode)
+	}
+
+	return fmt.Sprintf("contentful: unexpected status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+		case ErrNotFound:
+			return e.StatusCode == http.StatusNotFound
+		case ErrUnauthorized:
+			return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+
+	return false
+}
+
+// DecodeError is returned when a response isn't the JSON we expected
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("contentful: decoding response: %s", e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NotFoundError wraps ErrNotFound with what wasn't found
+func NotFoundError(contentType string, id string) error {
+	return fmt.Errorf("%w: %s entry %s", ErrNotFound, contentType, id)
+}
+
+// retryable reports whether a request that failed with err is worth retrying
+func retryable(err error) bool {
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+
+	var status *StatusError
+
+	return errors.As(err, &status) && status.StatusCode >= http.StatusInternalServerError
+}
+
+// statusError builds a StatusError from a failed response, picking the
+// message out of the error body Contentful sends
+func statusError(resp *http.Response) *StatusError {
+	var body struct {
+		Message string `json:"message"`
+		RequestID string `json:"requestId"`
+	}
+
+	// not every error comes from Contentful, a proxy may answer with HTML
+	json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&body)
+
+	requestID := resp.Header.Get("X-Contentful-Request-Id")
+
+	if requestID == "" {
+		requestID = body.RequestID
+	}
+
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Message: body.Message,
+		RequestID: requestID,
+	}
+}
+
+// retryAfter reads how long to wait from a rate limited response. Contentful
+// sends X-Contentful-RateLimit-Reset, Retry-After is used when it's missing.
+func retryAfter(resp *http.Response) time.Duration {
+	for _, header := range []string{"X-Contentful-RateLimit-Reset", "Retry-After"} {
+		seconds, err := strconv.Atoi(resp.Header.Get(header))
+
+		if err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	return 0
+}
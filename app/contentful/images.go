@@ -0,0 +1,70 @@
+package contentful
+
+import (
+	"net/url"
+	"strconv"
+)
+
+const (
+	FormatWebP string = "webp"
+	FormatAVIF string = "avif"
+	FormatJPG string = "jpg"
+	FormatPNG string = "png"
+
+	// FitFill resizes to the exact width and height, cropping what doesn't fit
+	FitFill string = "fill"
+	FitPad string = "pad"
+	FitScale string = "scale"
+	FitCrop string = "crop"
+	FitThumb string = "thumb"
+
+	// MaxImageSize is the largest width or height the Images API renders
+	MaxImageSize int = 4000
+)
+
+// ImageOptions are Images API parameters. Zero values are left out, so the
+// original size, format and fit are kept.
+type ImageOptions struct {
+	Width int
+	Height int
+	Format string
+	Fit string
+	Quality int
+}
+
+// ImageURL returns the URL of an image asset transformed by the Images API.
+// Parameters already on rawURL are kept unless opts overrides them. rawURL
+// is returned as is when it can't be parsed.
+func ImageURL(rawURL string, opts ImageOptions) string {
+	parsed, err := url.Parse(rawURL)
+
+	if err != nil || rawURL == "" {
+		return rawURL
+	}
+
+	query := parsed.Query()
+
+	if opts.Width > 0 {
+		query.Set("w", strconv.Itoa(min(opts.Width, MaxImageSize)))
+	}
+
+	if opts.Height > 0 {
+		query.Set("h", strconv.Itoa(min(opts.Height, MaxImageSize)))
+	}
+
+	if opts.Format != "" {
+		query.Set("fm", opts.Format)
+	}
+
+	if opts.Fit != "" {
+		query.Set("fit", opts.Fit)
+	}
+
+	if opts.Quality > 0 {
+		query.Set("q", strconv.Itoa(min(opts.Quality, 100)))
+	}
+
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
@@ -0,0 +1,112 @@
+package contentful
+
+import (
+	"encoding/json"
+)
+
+// Includes holds the linked entries and assets the Delivery API sends along
+// with a collection when the include parameter is set
+type Includes struct {
+	Entry []json.RawMessage `json:"Entry"`
+	Asset []json.RawMessage `json:"Asset"`
+}
+
+// LinkGraph indexes every entry and asset returned by one or more requests,
+// items and includes alike, so links can be resolved without caring which
+// order things were published in
+type LinkGraph struct {
+	entries map[string]json.RawMessage
+	assets map[string]json.RawMessage
+}
+
+type linkedSys struct {
+	Sys struct {
+		ID string `json:"id"`
+	} `json:"sys"`
+}
+
+func NewLinkGraph() *LinkGraph {
+	return &LinkGraph{
+		entries: map[string]json.RawMessage{},
+		assets: map[string]json.RawMessage{},
+	}
+}
+
+// Add indexes the items and includes of a collection
+func (g *LinkGraph) Add(collection *EntryCollection) {
+	for _, item := range collection.Items {
+		g.add(g.entries, item)
+	}
+
+	for _, item := range collection.Includes.Entry {
+		g.add(g.entries, item)
+	}
+
+	for _, item := range collection.Includes.Asset {
+		g.add(g.assets, item)
+	}
+}
+
+func (g *LinkGraph) add(index map[string]json.RawMessage, item json.RawMessage) {
+	var linked linkedSys
+
+	err := json.Unmarshal(item, &linked)
+
+	if err != nil || linked.Sys.ID == "" {
+		return
+	}
+
+	index[linked.Sys.ID] = item
+}
+
+// Entry returns the raw entry with id
+func (g *LinkGraph) Entry(id string) (json.RawMessage, bool) {
+	if g == nil {
+		return nil, false
+	}
+
+	entry, ok := g.entries[id]
+
+	return entry, ok
+}
+
+// Asset returns the raw asset with id
+func (g *LinkGraph) Asset(id string) (json.RawMessage, bool) {
+	if g == nil {
+		return nil, false
+	}
+
+	asset, ok := g.assets[id]
+
+	return asset, ok
+}
+
+// Resolve returns whatever link points to
+func (g *LinkGraph) Resolve(link ContentfulResponseLink) (json.RawMessage, bool) {
+	if link.Sys.LinkType == "Asset" {
+		return g.Asset(link.Sys.ID)
+	}
+
+	return g.Entry(link.Sys.ID)
+}
+
+// ResolveInto decodes whatever link points to into v. It reports false when
+// the link isn't in the graph or doesn't decode.
+func (g *LinkGraph) ResolveInto(link ContentfulResponseLink, v any) bool {
+	raw, ok := g.Resolve(link)
+
+	if !ok {
+		return false
+	}
+
+	return json.Unmarshal(raw, v) == nil
+}
+
+// Assets returns every asset in the graph by ID. The map must not be modified.
+func (g *LinkGraph) Assets() map[string]json.RawMessage {
+	if g == nil {
+		return nil
+	}
+
+	return g.assets
+}
@@ -0,0 +1,76 @@
+package contentful
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	// DeliveryBaseURL serves published content
+	DeliveryBaseURL string = "https://cdn.contentful.com"
+	// PreviewBaseURL serves the latest version of every entry, drafts
+	// included. It needs a Preview API token.
+	PreviewBaseURL string = "https://preview.contentful.com"
+
+	// DefaultEnvironment is the environment used unless WithEnvironment says
+	// otherwise
+	DefaultEnvironment string = "master"
+	// DefaultTimeout bounds a single request, including reading its body
+	DefaultTimeout time.Duration = 30 * time.Second
+	DefaultUserAgent string = "eatingisactivism"
+)
+
+// Option configures a client built by New
+type Option func(*Contentful)
+
+// WithHTTPClient makes requests through client instead of a new one
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Contentful) {
+		c.client = client
+	}
+}
+
+// WithTimeout sets the timeout of every request. It applies to the client
+// given to WithHTTPClient as well, without changing that client.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Contentful) {
+		c.timeout = timeout
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Contentful) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithLocale sets the locale sent with every entries and assets request.
+// Sync API entries carry every locale, see SyncItem.Localize.
+func WithLocale(locale string) Option {
+	return func(c *Contentful) {
+		c.Locale = locale
+	}
+}
+
+func WithEnvironment(environment string) Option {
+	return func(c *Contentful) {
+		c.Environment = environment
+	}
+}
+
+// WithPreview marks the client as talking to the Preview API. NewPreview sets
+// it, it's only needed to point a preview client somewhere other than
+// PreviewBaseURL through New.
+func WithPreview() Option {
+	return func(c *Contentful) {
+		c.preview = true
+	}
+}
+
+// WithMaxRetries sets how many times a rate limited or failed request is
+// retried, zero turns retries off
+func WithMaxRetries(retries int) Option {
+	return func(c *Contentful) {
+		c.maxRetries = retries
+	}
+}
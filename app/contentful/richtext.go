@@ -0,0 +1,262 @@
+package contentful
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"net/url"
+	"strings"
+)
+
+// Node is a single node of a Contentful rich text document. Block and inline
+// nodes carry Content, text nodes carry a Value and their Marks.
+type Node struct {
+	NodeType string `json:"nodeType"`
+	Data NodeData `json:"data"`
+	Content []Node `json:"content"`
+	Value string `json:"value"`
+	Marks []Mark `json:"marks"`
+}
+
+type NodeData struct {
+	URI string `json:"uri"`
+	Target *ContentfulResponseLink `json:"target"`
+}
+
+type Mark struct {
+	Type string `json:"type"`
+}
+
+var blockTags = map[string]string{
+	"paragraph": "p",
+	"heading-1": "h1",
+	"heading-2": "h2",
+	"heading-3": "h3",
+	"heading-4": "h4",
+	"heading-5": "h5",
+	"heading-6": "h6",
+	"ordered-list": "ol",
+	"unordered-list": "ul",
+	"list-item": "li",
+	"blockquote": "blockquote",
+	"table-row": "tr",
+	"table-cell": "td",
+	"table-header-cell": "th",
+}
+
+var markTags = map[string]string{
+	"bold": "strong",
+	"italic": "em",
+	"underline": "u",
+	"code": "code",
+	"superscript": "sup",
+	"subscript": "sub",
+	"strikethrough": "s",
+}
+
+// EntryResolver looks up the content type of a linked entry so the renderer
+// knows which EntryRenderer to hand it to
+type EntryResolver interface {
+	ResolveEntry(id string) (contentType string, ok bool)
+}
+
+// EntryRenderer renders an embedded-entry-block, embedded-entry-inline or
+// entry-hyperlink node. content holds the rendered children of the node, which
+// is only ever non-empty for entry-hyperlink. The returned HTML is written out
+// as is, so anything taken from the entry must be escaped by the renderer.
+type EntryRenderer func(node Node, id string, content string) template.HTML
+
+// AssetRenderer renders an embedded-asset-block node
+type AssetRenderer func(node Node, id string) template.HTML
+
+type RichTextRenderer struct {
+	resolver EntryResolver
+	entries map[string]EntryRenderer
+	asset AssetRenderer
+}
+
+var defaultRenderer = NewRichTextRenderer(nil)
+
+// NewRichTextRenderer creates a renderer that uses resolver to find the
+// content type of linked entries. Without a resolver, or without a renderer
+// registered for the content type, embedded entries render as nothing and
+// entry hyperlinks render as their text.
+func NewRichTextRenderer(resolver EntryResolver) *RichTextRenderer {
+	return &RichTextRenderer{
+		resolver: resolver,
+		entries: map[string]EntryRenderer{},
+	}
+}
+
+// RegisterEntry sets the renderer used for entries of contentType
+func (r *RichTextRenderer) RegisterEntry(contentType string, fn EntryRenderer) {
+	r.entries[contentType] = fn
+}
+
+// RegisterAsset sets the renderer used for embedded assets
+func (r *RichTextRenderer) RegisterAsset(fn AssetRenderer) {
+	r.asset = fn
+}
+
+// RichTextToHTML renders a rich text field using the default renderer, which
+// has no resolver and so skips embedded entries and assets.
+func RichTextToHTML(data json.RawMessage) template.HTML {
+	return defaultRenderer.Render(data)
+}
+
+// Render renders a rich text field as it comes from the Delivery API. All
+// text and attribute values are escaped, so the result is safe to print in a
+// template. Anything that can't be parsed renders as an empty string.
+func (r *RichTextRenderer) Render(data json.RawMessage) template.HTML {
+	if len(data) == 0 {
+		return ""
+	}
+
+	var document Node
+
+	err := json.Unmarshal(data, &document)
+
+	if err != nil {
+		return ""
+	}
+
+	if document.NodeType != "document" || len(document.Content) == 0 {
+		return ""
+	}
+
+	return template.HTML(r.NodeToString(document))
+}
+
+// NodeListToString renders each node in turn and concatenates the output
+func (r *RichTextRenderer) NodeListToString(nodes []Node) string {
+	var b strings.Builder
+
+	for _, node := range nodes {
+		b.WriteString(r.NodeToString(node))
+	}
+
+	return b.String()
+}
+
+// NodeToString renders a node and its children to HTML
+func (r *RichTextRenderer) NodeToString(node Node) string {
+	switch node.NodeType {
+		case "document":
+			return r.NodeListToString(node.Content)
+		case "text":
+			return textToString(node)
+		case "hr":
+			return "<hr>"
+		case "table":
+			return fmt.Sprintf("<table><tbody>%s</tbody></table>", r.NodeListToString(node.Content))
+		case "hyperlink":
+			return hyperlinkToString(node, r.NodeListToString(node.Content))
+		case "embedded-entry-block", "embedded-entry-inline":
+			return r.entryToString(node, "")
+		case "entry-hyperlink":
+			return r.entryToString(node, r.NodeListToString(node.Content))
+		case "embedded-asset-block":
+			return r.assetToString(node)
+		case "embedded-resource-block", "embedded-resource-inline":
+			// cross-space resources aren't something we can resolve
+			return ""
+		case "asset-hyperlink", "resource-hyperlink":
+			return r.NodeListToString(node.Content)
+	}
+
+	tag, ok := blockTags[node.NodeType]
+
+	if !ok {
+		// unknown node types still render their children so new node types
+		// added by Contentful don't swallow text
+		return r.NodeListToString(node.Content)
+	}
+
+	return fmt.Sprintf("<%s>%s</%s>", tag, r.NodeListToString(node.Content), tag)
+}
+
+func (r *RichTextRenderer) entryToString(node Node, content string) string {
+	id := targetID(node)
+
+	if id == "" || r.resolver == nil {
+		return content
+	}
+
+	contentType, ok := r.resolver.ResolveEntry(id)
+
+	if !ok {
+		return content
+	}
+
+	fn, ok := r.entries[contentType]
+
+	if !ok {
+		return content
+	}
+
+	return string(fn(node, id, content))
+}
+
+func (r *RichTextRenderer) assetToString(node Node) string {
+	id := targetID(node)
+
+	if id == "" || r.asset == nil {
+		return ""
+	}
+
+	return string(r.asset(node, id))
+}
+
+func targetID(node Node) string {
+	if node.Data.Target == nil {
+		return ""
+	}
+
+	return node.Data.Target.Sys.ID
+}
+
+func textToString(node Node) string {
+	value := html.EscapeString(node.Value)
+	value = strings.ReplaceAll(value, "\n", "<br>")
+
+	for _, mark := range node.Marks {
+		tag, ok := markTags[mark.Type]
+
+		if !ok {
+			continue
+		}
+
+		value = fmt.Sprintf("<%s>%s</%s>", tag, value, tag)
+	}
+
+	return value
+}
+
+func hyperlinkToString(node Node, content string) string {
+	if !isSafeURI(node.Data.URI) {
+		return content
+	}
+
+	return fmt.Sprintf(`<a href="%s" target="_blank" rel="noopener">%s</a>`, html.EscapeString(node.Data.URI), content)
+}
+
+// isSafeURI only allows links we'd be happy to put in an href
+func isSafeURI(uri string) bool {
+	if uri == "" {
+		return false
+	}
+
+	parsed, err := url.Parse(uri)
+
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+		case "", "http", "https", "mailto", "tel":
+			return true
+	}
+
+	return false
+}
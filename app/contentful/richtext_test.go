@@ -0,0 +1,247 @@
+package contentful
+
+import (
+	"encoding/json"
+	"html/template"
+	"testing"
+)
+
+// doc wraps block nodes in a rich text document
+func doc(blocks ...string) json.RawMessage {
+	content := "["
+
+	for i, block := range blocks {
+		if i > 0 {
+			content += ","
+		}
+
+		content += block
+	}
+
+	return json.RawMessage(`{"nodeType":"document","data":{},"content":` + content + `]}`)
+}
+
+func paragraph(content string) string {
+	return `{"nodeType":"paragraph","data":{},"content":[` + content + `]}`
+}
+
+func text(value string, marks ...string) string {
+	markList := "["
+
+	for i, mark := range marks {
+		if i > 0 {
+			markList += ","
+		}
+
+		markList += `{"type":"` + mark + `"}`
+	}
+
+	value, _ = jsonString(value)
+
+	return `{"nodeType":"text","value":` + value + `,"marks":` + markList + `],"data":{}}`
+}
+
+func link(uri string, content string) string {
+	uri, _ = jsonString(uri)
+
+	return `{"nodeType":"hyperlink","data":{"uri":` + uri + `},"content":[` + content + `]}`
+}
+
+func jsonString(s string) (string, error) {
+	data, err := json.Marshal(s)
+
+	return string(data), err
+}
+
+func TestRichTextToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		data json.RawMessage
+		want template.HTML
+	}{
+		{
+			name: "empty",
+			data: nil,
+			want: "",
+		},
+		{
+			name: "not json",
+			data: json.RawMessage(`{"nodeType":`),
+			want: "",
+		},
+		{
+			name: "not a document",
+			data: json.RawMessage(paragraph(text("hi"))),
+			want: "",
+		},
+		{
+			name: "paragraph",
+			data: doc(paragraph(text("Plant based since 2010"))),
+			want: "<p>Plant based since 2010</p>",
+		},
+		{
+			name: "script in text",
+			data: doc(paragraph(text(`<script>alert("hi")</script>`))),
+			want: "<p>&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;</p>",
+		},
+		{
+			name: "line breaks",
+			data: doc(paragraph(text("Open\nevery day"))),
+			want: "<p>Open<br>every day</p>",
+		},
+		{
+			name: "nested marks",
+			data: doc(paragraph(text("vegan", "bold", "italic", "underline"))),
+			want: "<p><u><em><strong>vegan</strong></em></u></p>",
+		},
+		{
+			name: "unknown mark",
+			data: doc(paragraph(text("vegan", "sparkle", "code"))),
+			want: "<p><code>vegan</code></p>",
+		},
+		{
+			name: "https link",
+			data: doc(paragraph(link("https://example.com/?a=1&b=2", text("menu")))),
+			want: `<p><a href="https://example.com/?a=1&amp;b=2" target="_blank" rel="noopener">menu</a></p>`,
+		},
+		{
+			name: "mailto link",
+			data: doc(paragraph(link("mailto:hello@example.com", text("email us")))),
+			want: `<p><a href="mailto:hello@example.com" target="_blank" rel="noopener">email us</a></p>`,
+		},
+		{
+			name: "link with a quote",
+			data: doc(paragraph(link(`https://example.com/" onclick="alert(1)`, text("menu")))),
+			want: `<p><a href="https://example.com/&#34; onclick=&#34;alert(1)" target="_blank" rel="noopener">menu</a></p>`,
+		},
+		{
+			name: "javascript link",
+			data: doc(paragraph(link("javascript:alert(1)", text("click")))),
+			want: "<p>click</p>",
+		},
+		{
+			name: "mixed case javascript link",
+			data: doc(paragraph(link("JaVaScript:alert(1)", text("click")))),
+			want: "<p>click</p>",
+		},
+		{
+			name: "javascript link with leading space",
+			data: doc(paragraph(link(" javascript:alert(1)", text("click")))),
+			want: "<p>click</p>",
+		},
+		{
+			name: "javascript link with a tab",
+			data: doc(paragraph(link("java\tscript:alert(1)", text("click")))),
+			want: "<p>click</p>",
+		},
+		{
+			name: "data link",
+			data: doc(paragraph(link("data:text/html,<script>alert(1)</script>", text("click")))),
+			want: "<p>click</p>",
+		},
+		{
+			name: "unordered list",
+			data: doc(`{"nodeType":"unordered-list","data":{},"content":[
+				{"nodeType":"list-item","data":{},"content":[` + paragraph(text("oat milk")) + `]},
+				{"nodeType":"list-item","data":{},"content":[` + paragraph(text("tofu")) + `]}
+			]}`),
+			want: "<ul><li><p>oat milk</p></li><li><p>tofu</p></li></ul>",
+		},
+		{
+			name: "ordered list",
+			data: doc(`{"nodeType":"ordered-list","data":{},"content":[
+				{"nodeType":"list-item","data":{},"content":[` + paragraph(text("order")) + `]}
+			]}`),
+			want: "<ol><li><p>order</p></li></ol>",
+		},
+		{
+			name: "table",
+			data: doc(`{"nodeType":"table","data":{},"content":[
+				{"nodeType":"table-row","data":{},"content":[
+					{"nodeType":"table-header-cell","data":{},"content":[` + paragraph(text("Day")) + `]},
+					{"nodeType":"table-header-cell","data":{},"content":[` + paragraph(text("Hours")) + `]}
+				]},
+				{"nodeType":"table-row","data":{},"content":[
+					{"nodeType":"table-cell","data":{},"content":[` + paragraph(text("Mon")) + `]},
+					{"nodeType":"table-cell","data":{},"content":[` + paragraph(text("9 < 5")) + `]}
+				]}
+			]}`),
+			want: "<table><tbody><tr><th><p>Day</p></th><th><p>Hours</p></th></tr><tr><td><p>Mon</p></td><td><p>9 &lt; 5</p></td></tr></tbody></table>",
+		},
+		{
+			name: "hr",
+			data: doc(paragraph(text("above")), `{"nodeType":"hr","data":{},"content":[]}`, paragraph(text("below"))),
+			want: "<p>above</p><hr><p>below</p>",
+		},
+		{
+			name: "heading and quote",
+			data: doc(`{"nodeType":"heading-2","data":{},"content":[` + text("Menu") + `]}`, `{"nodeType":"blockquote","data":{},"content":[` + paragraph(text("Best falafel")) + `]}`),
+			want: "<h2>Menu</h2><blockquote><p>Best falafel</p></blockquote>",
+		},
+		{
+			name: "unknown node type keeps its text",
+			data: doc(`{"nodeType":"callout","data":{},"content":[` + paragraph(text("<b>new</b> node")) + `]}`),
+			want: "<p>&lt;b&gt;new&lt;/b&gt; node</p>",
+		},
+		{
+			name: "entry without a resolver",
+			data: doc(paragraph(`{"nodeType":"entry-hyperlink","data":{"target":{"sys":{"id":"abc","type":"Link","linkType":"Entry"}}},"content":[` + text("a place") + `]}`), `{"nodeType":"embedded-entry-block","data":{"target":{"sys":{"id":"abc","type":"Link","linkType":"Entry"}}},"content":[]}`),
+			want: "<p>a place</p>",
+		},
+		{
+			name: "asset without a renderer",
+			data: doc(`{"nodeType":"embedded-asset-block","data":{"target":{"sys":{"id":"abc","type":"Link","linkType":"Asset"}}},"content":[]}`),
+			want: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := RichTextToHTML(test.data)
+
+			if got != test.want {
+				t.Errorf("got  %s\nwant %s", got, test.want)
+			}
+		})
+	}
+}
+
+type fakeResolver map[string]string
+
+func (r fakeResolver) ResolveEntry(id string) (string, bool) {
+	contentType, ok := r[id]
+
+	return contentType, ok
+}
+
+func TestRichTextRendererEntries(t *testing.T) {
+	r := NewRichTextRenderer(fakeResolver{"green-kitchen": "location", "vegan": "standard"})
+	r.RegisterEntry("location", func(node Node, id string, content string) template.HTML {
+		if content == "" {
+			content = id
+		}
+
+		return template.HTML(`<a href="/locations/` + id + `">` + content + `</a>`)
+	})
+	r.RegisterAsset(func(node Node, id string) template.HTML {
+		return template.HTML(`<img src="` + id + `">`)
+	})
+
+	entryLink := func(id string) string {
+		return `{"nodeType":"entry-hyperlink","data":{"target":{"sys":{"id":"` + id + `","type":"Link","linkType":"Entry"}}},"content":[` + text("see <here>") + `]}`
+	}
+
+	got := r.Render(doc(
+		paragraph(entryLink("green-kitchen") + "," + entryLink("vegan") + "," + entryLink("missing")),
+		`{"nodeType":"embedded-entry-block","data":{"target":{"sys":{"id":"green-kitchen","type":"Link","linkType":"Entry"}}},"content":[]}`,
+		`{"nodeType":"embedded-asset-block","data":{"target":{"sys":{"id":"photo","type":"Link","linkType":"Asset"}}},"content":[]}`,
+	))
+
+	// a registered content type goes to its renderer, anything else keeps
+	// its escaped text
+	want := template.HTML(`<p><a href="/locations/green-kitchen">see &lt;here&gt;</a>see &lt;here&gt;see &lt;here&gt;</p><a href="/locations/green-kitchen">green-kitchen</a><img src="photo">`)
+
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
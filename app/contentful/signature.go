@@ -0,0 +1,105 @@
+package contentful
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSignature string = "X-Contentful-Signature"
+	HeaderSignedHeaders string = "X-Contentful-Signed-Headers"
+	HeaderTimestamp string = "X-Contentful-Timestamp"
+
+	// DefaultSignatureTTL matches the window Contentful's own libraries use
+	DefaultSignatureTTL time.Duration = 30 * time.Second
+)
+
+var (
+	ErrMissingSignature = errors.New("contentful: request is not signed")
+	ErrInvalidSignature = errors.New("contentful: request signature does not match")
+	ErrExpiredSignature = errors.New("contentful: request signature has expired")
+)
+
+// VerifyRequest checks a webhook request signed by Contentful's request
+// verification. The signature is an HMAC-SHA256 of the canonical request,
+// which is the method, the path with its query, the signed headers and the
+// body, separated by newlines. Requests signed more than ttl before now are
+// rejected so a captured request can't be replayed later.
+func VerifyRequest(secret string, req *http.Request, body []byte, ttl time.Duration, now time.Time) error {
+	signature := req.Header.Get(HeaderSignature)
+	signedHeaders := req.Header.Get(HeaderSignedHeaders)
+	timestamp := req.Header.Get(HeaderTimestamp)
+
+	if signature == "" || signedHeaders == "" || timestamp == "" {
+		return ErrMissingSignature
+	}
+
+	names := strings.Split(strings.ToLower(signedHeaders), ",")
+
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+
+	// the timestamp has to be covered by the signature, otherwise it could be
+	// swapped out to replay the request
+	if !containsHeader(names, HeaderTimestamp) || !containsHeader(names, HeaderSignedHeaders) {
+		return ErrInvalidSignature
+	}
+
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	signedAt := time.UnixMilli(millis)
+
+	if now.Sub(signedAt) > ttl || signedAt.Sub(now) > ttl {
+		return ErrExpiredSignature
+	}
+
+	headers := make([]string, 0, len(names))
+
+	for _, name := range names {
+		headers = append(headers, name + ":" + strings.TrimSpace(req.Header.Get(name)))
+	}
+
+	expected := Sign(secret, req.Method, req.URL.RequestURI(), headers, body)
+
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Sign returns the hex encoded signature of a canonical request. headers are
+// "name:value" pairs with lowercase names, in the order they are listed in the
+// signed headers header.
+func Sign(secret string, method string, path string, headers []string, body []byte) string {
+	canonical := fmt.Sprintf("%s\n%s\n%s\n%s", strings.ToUpper(method), path, strings.Join(headers, ";"), body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(canonical))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func containsHeader(names []string, header string) bool {
+	header = strings.ToLower(header)
+
+	for _, name := range names {
+		if name == header {
+			return true
+		}
+	}
+
+	return false
+}
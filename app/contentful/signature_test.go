@@ -0,0 +1,146 @@
+package contentful
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// a publish webhook as Contentful sends it. The signature was worked out from
+// Contentful's documented canonical request with HMAC-SHA256 outside of Sign,
+// so a mistake in Sign can't sign its own fixture.
+const (
+	recordedSecret = "9dJJkZ4xVq2bT8wLmPs0aRcYf6HnE3uW"
+	recordedPath = "/api/v1/webhook"
+	recordedBody = `{"sys":{"id":"4BqrajvA8E6qwgkieoqmqO","type":"Entry","contentType":{"sys":{"id":"location"}}},"fields":{"slug":{"en-US":"the-green-kitchen"}}}`
+	recordedSignedHeaders = "x-contentful-signed-headers,x-contentful-timestamp,x-contentful-topic,content-type"
+	recordedTimestamp = "1760000000000"
+	recordedSignature = "b44a4a4281ca5176df31830639d4db39ab27cc680b3e8c94078616289ecd4cad"
+)
+
+var recordedAt = time.UnixMilli(1760000000000)
+
+func recordedRequest() *http.Request {
+	req := httptest.NewRequest(http.MethodPost, recordedPath, strings.NewReader(recordedBody))
+	req.Header.Set("Content-Type", "application/vnd.contentful.management.v1+json")
+	req.Header.Set("X-Contentful-Topic", "ContentManagement.Entry.publish")
+	req.Header.Set(HeaderSignedHeaders, recordedSignedHeaders)
+	req.Header.Set(HeaderTimestamp, recordedTimestamp)
+	req.Header.Set(HeaderSignature, recordedSignature)
+
+	return req
+}
+
+func TestVerifyRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		modify func(req *http.Request) []byte
+		now time.Time
+		want error
+	}{
+		{
+			name: "recorded request",
+			now: recordedAt.Add(5 * time.Second),
+		},
+		{
+			name: "tampered body",
+			modify: func(req *http.Request) []byte {
+				return []byte(strings.Replace(recordedBody, "the-green-kitchen", "the-red-kitchen", 1))
+			},
+			now: recordedAt,
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "tampered signed header",
+			modify: func(req *http.Request) []byte {
+				req.Header.Set("X-Contentful-Topic", "ContentManagement.Entry.delete")
+				return nil
+			},
+			now: recordedAt,
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "timestamp past the TTL",
+			now: recordedAt.Add(DefaultSignatureTTL + time.Second),
+			want: ErrExpiredSignature,
+		},
+		{
+			name: "timestamp ahead of the TTL",
+			now: recordedAt.Add(-DefaultSignatureTTL - time.Second),
+			want: ErrExpiredSignature,
+		},
+		{
+			name: "timestamp not signed",
+			modify: func(req *http.Request) []byte {
+				req.Header.Set(HeaderSignedHeaders, "x-contentful-signed-headers,x-contentful-topic,content-type")
+				return nil
+			},
+			now: recordedAt,
+			want: ErrInvalidSignature,
+		},
+		{
+			name: "missing signature",
+			modify: func(req *http.Request) []byte {
+				req.Header.Del(HeaderSignature)
+				return nil
+			},
+			now: recordedAt,
+			want: ErrMissingSignature,
+		},
+		{
+			name: "missing timestamp",
+			modify: func(req *http.Request) []byte {
+				req.Header.Del(HeaderTimestamp)
+				return nil
+			},
+			now: recordedAt,
+			want: ErrMissingSignature,
+		},
+		{
+			name: "wrong secret",
+			modify: func(req *http.Request) []byte {
+				req.Header.Set(HeaderSignature, Sign("another secret", http.MethodPost, recordedPath, nil, []byte(recordedBody)))
+				return nil
+			},
+			now: recordedAt,
+			want: ErrInvalidSignature,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := recordedRequest()
+			body := []byte(recordedBody)
+
+			if test.modify != nil {
+				if modified := test.modify(req); modified != nil {
+					body = modified
+				}
+			}
+
+			err := VerifyRequest(recordedSecret, req, body, DefaultSignatureTTL, test.now)
+
+			if !errors.Is(err, test.want) {
+				t.Errorf("got %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestSignMatchesRecordedRequest(t *testing.T) {
+	headers := []string{
+		"x-contentful-signed-headers:" + recordedSignedHeaders,
+		"x-contentful-timestamp:" + recordedTimestamp,
+		"x-contentful-topic:ContentManagement.Entry.publish",
+		"content-type:application/vnd.contentful.management.v1+json",
+	}
+
+	got := Sign(recordedSecret, http.MethodPost, recordedPath, headers, []byte(recordedBody))
+
+	if got != recordedSignature {
+		t.Errorf("got %s, want %s", got, recordedSignature)
+	}
+}
@@ -0,0 +1,152 @@
+package contentful
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+const (
+	SyncEntry string = "Entry"
+	SyncDeletedEntry string = "DeletedEntry"
+	SyncAsset string = "Asset"
+	SyncDeletedAsset string = "DeletedAsset"
+)
+
+type SyncSys struct {
+	ID string `json:"id"`
+	Type string `json:"type"`
+	ContentType *struct {
+		Sys struct {
+			ID string `json:"id"`
+		} `json:"sys"`
+	} `json:"contentType,omitempty"`
+}
+
+// SyncItem is an entry, asset or deletion from the Sync API. Unlike the
+// entries endpoint, fields hold a value per locale.
+type SyncItem struct {
+	Sys SyncSys `json:"sys"`
+	Fields map[string]map[string]json.RawMessage `json:"fields"`
+}
+
+type SyncResponse struct {
+	Items []SyncItem `json:"items"`
+	NextPageURL string `json:"nextPageUrl"`
+	NextSyncURL string `json:"nextSyncUrl"`
+	Message string `json:"message"`
+}
+
+// SyncResult holds every item of a sync and the token to pass to the next one
+type SyncResult struct {
+	Items []SyncItem
+	NextSyncToken string
+}
+
+// ContentTypeID returns the content type of an entry, which is empty for
+// assets and for deletions
+func (item SyncItem) ContentTypeID() string {
+	if item.Sys.ContentType == nil {
+		return ""
+	}
+
+	return item.Sys.ContentType.Sys.ID
+}
+
+// Localize flattens the fields of the item to a single locale so it decodes
+// into the same structs as an entry from the entries endpoint. Fields missing
+// in locale are taken from fallback, and left out when fallback is missing
+// them too.
+func (item SyncItem) Localize(locale string, fallback string) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+
+	for name, values := range item.Fields {
+		value, ok := values[locale]
+
+		if !ok {
+			value, ok = values[fallback]
+		}
+
+		if ok {
+			fields[name] = value
+		}
+	}
+
+	return json.Marshal(struct {
+		Sys SyncSys `json:"sys"`
+		Fields map[string]json.RawMessage `json:"fields"`
+	}{
+		Sys: item.Sys,
+		Fields: fields,
+	})
+}
+
+// Sync runs the Sync API. An empty token starts an initial sync that returns
+// everything, otherwise only what changed since the sync that handed out the
+// token is returned. Every page is fetched before returning.
+func (c *Contentful) Sync(ctx context.Context, token string) (*SyncResult, error) {
+	syncURL := fmt.Sprintf("%s/sync?initial=true", c.environmentURL())
+
+	if token != "" {
+		syncURL = fmt.Sprintf("%s/sync?sync_token=%s", c.environmentURL(), url.QueryEscape(token))
+	}
+
+	result := &SyncResult{}
+
+	for {
+		body, err := c.get(ctx, syncURL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var response SyncResponse
+
+		err = json.Unmarshal(body, &response)
+
+		if err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+
+		result.Items = append(result.Items, response.Items...)
+
+		if response.NextPageURL != "" {
+			nextToken, err := syncToken(response.NextPageURL)
+
+			if err != nil {
+				return nil, err
+			}
+
+			syncURL = fmt.Sprintf("%s/sync?sync_token=%s", c.environmentURL(), url.QueryEscape(nextToken))
+			continue
+		}
+
+		nextToken, err := syncToken(response.NextSyncURL)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.NextSyncToken = nextToken
+
+		return result, nil
+	}
+}
+
+// syncToken pulls the sync_token out of a nextPageUrl or nextSyncUrl
+func syncToken(rawURL string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+
+	if err != nil {
+		return "", err
+	}
+
+	token := parsed.Query().Get("sync_token")
+
+	if token == "" {
+		return "", fmt.Errorf("error: no sync token in %q", rawURL)
+	}
+
+	return token, nil
+}
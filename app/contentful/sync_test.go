@@ -0,0 +1,193 @@
+package contentful
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeSync is a Sync API that hands out pages by sync token, recording the
+// queries it was sent
+type fakeSync struct {
+	// pages are the responses by sync_token, "" for the initial sync
+	pages map[string]string
+
+	mu sync.Mutex
+	paths []string
+	queries []string
+}
+
+func (f *fakeSync) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.paths = append(f.paths, r.URL.Path)
+	f.queries = append(f.queries, r.URL.RawQuery)
+	f.mu.Unlock()
+
+	token := r.URL.Query().Get("sync_token")
+
+	if token == "" && r.URL.Query().Get("initial") != "true" {
+		http.Error(w, `{"message":"no sync token"}`, http.StatusBadRequest)
+		return
+	}
+
+	page, ok := f.pages[token]
+
+	if !ok {
+		http.Error(w, `{"message":"unknown sync token"}`, http.StatusBadRequest)
+		return
+	}
+
+	io.WriteString(w, page)
+}
+
+func syncPage(items string, next string) string {
+	return fmt.Sprintf(`{"sys":{"type":"Array"},"items":[%s],%s}`, items, next)
+}
+
+const (
+	syncedStandard = `{"sys":{"id":"vegan","type":"Entry","contentType":{"sys":{"id":"standard"}}},"fields":{"title":{"en-US":"Vegan"},"slug":{"en-US":"vegan"}}}`
+	syncedLocation = `{"sys":{"id":"green-kitchen","type":"Entry","contentType":{"sys":{"id":"location"}}},"fields":{"name":{"en-US":"Green Kitchen"},"slug":{"en-US":"green-kitchen"}}}`
+	syncedDeletion = `{"sys":{"id":"closed-cafe","type":"DeletedEntry"}}`
+)
+
+func TestSyncFollowsPages(t *testing.T) {
+	fake := &fakeSync{pages: map[string]string{
+		"": syncPage(syncedStandard, `"nextPageUrl":"https://cdn.contentful.com/spaces/space/environments/master/sync?sync_token=page-2"`),
+		"page-2": syncPage(syncedLocation + "," + syncedDeletion, `"nextSyncUrl":"https://cdn.contentful.com/spaces/space/environments/master/sync?sync_token=next+sync%2F1"`),
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	result, err := New("token", "space", server.URL).Sync(context.Background(), "")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Items) != 3 {
+		t.Fatalf("got %d items, want 3", len(result.Items))
+	}
+
+	ids := []string{}
+
+	for _, item := range result.Items {
+		ids = append(ids, item.Sys.ID)
+	}
+
+	if fmt.Sprint(ids) != "[vegan green-kitchen closed-cafe]" {
+		t.Errorf("got items %v", ids)
+	}
+
+	if result.NextSyncToken != "next sync/1" {
+		t.Errorf("got next sync token %q, want it read from nextSyncUrl", result.NextSyncToken)
+	}
+
+	wantQueries := []string{"initial=true", "sync_token=page-2"}
+
+	if fmt.Sprint(fake.queries) != fmt.Sprint(wantQueries) {
+		t.Errorf("sent %v, want %v", fake.queries, wantQueries)
+	}
+
+	for _, path := range fake.paths {
+		if path != "/spaces/space/environments/master/sync" {
+			t.Errorf("requested %s", path)
+		}
+	}
+}
+
+func TestSyncWithToken(t *testing.T) {
+	fake := &fakeSync{pages: map[string]string{
+		"a+b/c": syncPage(syncedDeletion, `"nextSyncUrl":"https://cdn.contentful.com/sync?sync_token=d"`),
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	result, err := New("token", "space", server.URL).Sync(context.Background(), "a+b/c")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Items) != 1 || result.Items[0].Sys.Type != SyncDeletedEntry || result.Items[0].ContentTypeID() != "" {
+		t.Errorf("got items %+v, want a deletion without a content type", result.Items)
+	}
+
+	if result.NextSyncToken != "d" {
+		t.Errorf("got next sync token %q", result.NextSyncToken)
+	}
+}
+
+func TestSyncErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		page string
+	}{
+		{name: "no next sync url", page: syncPage("", `"nextSyncUrl":""`)},
+		{name: "no token in next page url", page: syncPage("", `"nextPageUrl":"https://cdn.contentful.com/sync"`)},
+		{name: "not json", page: "<html>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(&fakeSync{pages: map[string]string{"": test.page}})
+			defer server.Close()
+
+			result, err := New("token", "space", server.URL).Sync(context.Background(), "")
+
+			if err == nil {
+				t.Fatalf("got %+v, want an error", result)
+			}
+		})
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	var item SyncItem
+
+	err := json.Unmarshal([]byte(`{
+		"sys": {"id": "green-kitchen", "type": "Entry", "contentType": {"sys": {"id": "location"}}},
+		"fields": {
+			"name": {"en-US": "Green Kitchen", "de": "Grüne Küche"},
+			"slug": {"en-US": "green-kitchen"},
+			"url": {"fr": "https://example.fr"}
+		}
+	}`), &item)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := item.Localize("de", "en-US")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entry struct {
+		Sys SyncSys `json:"sys"`
+		Fields map[string]string `json:"fields"`
+	}
+
+	err = json.Unmarshal(data, &entry)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// name is translated, slug falls back to the default locale and url is
+	// in neither so it's left out
+	want := map[string]string{"name": "Grüne Küche", "slug": "green-kitchen"}
+
+	if fmt.Sprint(entry.Fields) != fmt.Sprint(want) {
+		t.Errorf("got fields %v, want %v", entry.Fields, want)
+	}
+
+	if entry.Sys.ID != "green-kitchen" || entry.Sys.ContentType == nil || entry.Sys.ContentType.Sys.ID != "location" {
+		t.Errorf("got sys %+v", entry.Sys)
+	}
+}
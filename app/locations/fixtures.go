@@ -0,0 +1,201 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fixtures is the layout of a fixture file, YAML files use the same field
+// names. Locations refer to their standard and tags by ID or slug, and to
+// their images by ID.
+type fixtures struct {
+	Standards []LocationStandard `json:"standards"`
+	Tags []LocationTag `json:"tags"`
+	Assets []Asset `json:"assets"`
+	Locations []fixtureLocation `json:"locations"`
+}
+
+type fixtureLocation struct {
+	ID string `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+	Url string `json:"url"`
+	ShortDescription string `json:"shortDescription"`
+	// LongDescription is a rich text document
+	LongDescription any `json:"longDescription"`
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+	Standard string `json:"standard"`
+	Tags []string `json:"tags"`
+	HeroImage string `json:"heroImage"`
+	Gallery []string `json:"gallery"`
+	Draft bool `json:"draft"`
+}
+
+// FixtureSource loads locations from a local JSON or YAML file, so the site
+// runs without Contentful. The file is read again on every refresh.
+type FixtureSource struct {
+	path string
+	store *Store
+}
+
+func NewFixtureSource(path string, store *Store) *FixtureSource {
+	return &FixtureSource{
+		path: path,
+		store: store,
+	}
+}
+
+func (f *FixtureSource) Refresh(ctx context.Context) error {
+	next, err := f.Load()
+
+	if err != nil {
+		return err
+	}
+
+	f.store.Swap(next)
+
+	return nil
+}
+
+// Restore does nothing, the file is local so there's nothing to wait for
+func (f *FixtureSource) Restore() bool {
+	return false
+}
+
+// Load reads the file into a new snapshot
+func (f *FixtureSource) Load() (Snapshot, error) {
+	data, err := os.ReadFile(f.path)
+
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("reading fixtures: %w", err)
+	}
+
+	var file fixtures
+
+	switch strings.ToLower(filepath.Ext(f.path)) {
+		case ".json":
+			err = json.Unmarshal(data, &file)
+		case ".yaml", ".yml":
+			// going through JSON lets YAML use the same field names
+			var parsed any
+
+			err = yaml.Unmarshal(data, &parsed)
+
+			if err == nil {
+				data, err = json.Marshal(parsed)
+			}
+
+			if err == nil {
+				err = json.Unmarshal(data, &file)
+			}
+		default:
+			return Snapshot{}, fmt.Errorf("fixtures %s should be .json, .yaml or .yml", f.path)
+	}
+
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("decoding fixtures %s: %w", f.path, err)
+	}
+
+	return file.snapshot()
+}
+
+func (file fixtures) snapshot() (Snapshot, error) {
+	next := NewSnapshot()
+	standardIDs := map[string]string{}
+	tagIDs := map[string]string{}
+	assetIDs := map[string]string{}
+
+	for _, standard := range file.Standards {
+		standard.ID = fixtureID(standard.ID, standard.Slug)
+		next.Standards[standard.Slug] = standard
+		standardIDs[standard.ID] = standard.ID
+		standardIDs[standard.Slug] = standard.ID
+	}
+
+	for _, tag := range file.Tags {
+		tag.ID = fixtureID(tag.ID, tag.Slug)
+		next.Tags[tag.Slug] = tag
+		tagIDs[tag.ID] = tag.ID
+		tagIDs[tag.Slug] = tag.ID
+	}
+
+	for _, asset := range file.Assets {
+		next.Assets[asset.ID] = asset
+		assetIDs[asset.ID] = asset.ID
+	}
+
+	for _, entry := range file.Locations {
+		if entry.Slug == "" {
+			return Snapshot{}, fmt.Errorf("fixture location %q has no slug", entry.Name)
+		}
+
+		var longDescription json.RawMessage
+
+		if entry.LongDescription != nil {
+			data, err := json.Marshal(entry.LongDescription)
+
+			if err != nil {
+				return Snapshot{}, fmt.Errorf("fixture location %s: %w", entry.Slug, err)
+			}
+
+			longDescription = data
+		}
+
+		location := Location{
+			ID: fixtureID(entry.ID, entry.Slug),
+			Name: entry.Name,
+			Slug: entry.Slug,
+			Url: entry.Url,
+			ShortDescription: entry.ShortDescription,
+			LongDescription: longDescription,
+			Lat: entry.Lat,
+			Lng: entry.Lng,
+			Draft: entry.Draft,
+		}
+
+		links := LocationLinks{
+			StandardID: standardIDs[entry.Standard],
+			TagIDs: []string{},
+			HeroImageID: assetIDs[entry.HeroImage],
+			GalleryIDs: []string{},
+		}
+
+		for _, tag := range entry.Tags {
+			if id, ok := tagIDs[tag]; ok {
+				links.TagIDs = append(links.TagIDs, id)
+			}
+		}
+
+		for _, asset := range entry.Gallery {
+			if id, ok := assetIDs[asset]; ok {
+				links.GalleryIDs = append(links.GalleryIDs, id)
+			}
+		}
+
+		next.Locations[location.Slug] = location
+		next.Links[location.ID] = links
+	}
+
+	// fills in the standard, tags and images of every location
+	next.relink(func(links LocationLinks) bool {
+		return true
+	})
+
+	return next, nil
+}
+
+// fixtureID falls back to the slug for entries without an ID
+func fixtureID(id string, slug string) string {
+	if id == "" {
+		return slug
+	}
+
+	return id
+}
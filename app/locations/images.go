@@ -0,0 +1,100 @@
+package locations
+
+import (
+	"fmt"
+	"strings"
+
+	"eatingisactivism/app/contentful"
+)
+
+// IsImage reports whether the asset is an image the Images API can transform
+func (asset Asset) IsImage() bool {
+	return strings.HasPrefix(asset.ContentType, "image/") && asset.URL != ""
+}
+
+// Image returns the URL of the asset as a WebP image width pixels wide. With
+// a height as well it is cropped to fill both, a zero height keeps the aspect
+// ratio. Assets that aren't images are returned as is.
+func (asset Asset) Image(width int, height int) string {
+	if !asset.IsImage() {
+		return asset.URL
+	}
+
+	opts := contentful.ImageOptions{
+		Width: width,
+		Height: height,
+		Format: contentful.FormatWebP,
+	}
+
+	if width > 0 && height > 0 {
+		opts.Fit = contentful.FitFill
+	}
+
+	return contentful.ImageURL(asset.URL, opts)
+}
+
+// Srcset returns a srcset of the asset at each of widths, skipping widths
+// larger than the original
+func (asset Asset) Srcset(widths ...int) string {
+	if !asset.IsImage() {
+		return ""
+	}
+
+	candidates := []string{}
+
+	for _, width := range widths {
+		if asset.Width > 0 && width > asset.Width {
+			continue
+		}
+
+		candidates = append(candidates, fmt.Sprintf("%s %dw", asset.Image(width, 0), width))
+	}
+
+	return strings.Join(candidates, ", ")
+}
+
+// resolveAsset finds a linked asset in graph, falling back to the assets in
+// snapshot
+func resolveAsset(link contentful.ContentfulResponseLink, graph *contentful.LinkGraph, snapshot Snapshot) (Asset, bool) {
+	if link.Sys.ID == "" {
+		return Asset{}, false
+	}
+
+	var entry contentful.ContentfulAsset
+
+	if graph.ResolveInto(link, &entry) {
+		return assetFromContentful(entry), true
+	}
+
+	asset, ok := snapshot.Assets[link.Sys.ID]
+
+	return asset, ok
+}
+
+// heroImage returns the hero image with ID id from snapshot, or nil when
+// there isn't one
+func (snapshot Snapshot) heroImage(id string) *Asset {
+	asset, ok := snapshot.Assets[id]
+
+	if id == "" || !ok {
+		return nil
+	}
+
+	return &asset
+}
+
+// gallery returns the assets with ids from snapshot, leaving out those it
+// doesn't have
+func (snapshot Snapshot) gallery(ids []string) []Asset {
+	assets := []Asset{}
+
+	for _, id := range ids {
+		asset, ok := snapshot.Assets[id]
+
+		if ok {
+			assets = append(assets, asset)
+		}
+	}
+
+	return assets
+}
@@ -0,0 +1,325 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"eatingisactivism/app/contentful"
+)
+
+// Translation is the text of an entry in a locale other than the default.
+// Standards and tags only have a name.
+type Translation struct {
+	Name string `json:"name,omitempty"`
+	ShortDescription string `json:"shortDescription,omitempty"`
+	LongDescription json.RawMessage `json:"longDescription,omitempty"`
+}
+
+// Translations are keyed by locale
+type Translations map[string]Translation
+
+// Locales returns the locales content is available in, the default first
+func (svc *Service) Locales() []string {
+	return slices.Clone(svc.locales)
+}
+
+func (svc *Service) DefaultLocale() string {
+	return svc.locales[0]
+}
+
+// localeList puts defaultLocale ahead of others, leaving out repeats
+func localeList(defaultLocale string, others []string) []string {
+	found := []string{defaultLocale}
+
+	for _, locale := range others {
+		if !slices.Contains(found, locale) {
+			found = append(found, locale)
+		}
+	}
+
+	return found
+}
+
+// Localized returns the location with its text, standard and tags in locale.
+// Text that wasn't translated stays in the default locale.
+func (location Location) Localized(locale string) Location {
+	translation, ok := location.Translations[locale]
+
+	if ok {
+		if translation.Name != "" {
+			location.Name = translation.Name
+		}
+
+		if translation.ShortDescription != "" {
+			location.ShortDescription = translation.ShortDescription
+		}
+
+		if len(translation.LongDescription) > 0 && string(translation.LongDescription) != "null" {
+			location.LongDescription = translation.LongDescription
+		}
+	}
+
+	location.Translations = nil
+	location.Standard = location.Standard.Localized(locale)
+	tags := make([]LocationTag, 0, len(location.Tags))
+
+	for _, tag := range location.Tags {
+		tags = append(tags, tag.Localized(locale))
+	}
+
+	location.Tags = tags
+
+	return location
+}
+
+func (standard LocationStandard) Localized(locale string) LocationStandard {
+	if translation, ok := standard.Translations[locale]; ok && translation.Name != "" {
+		standard.Name = translation.Name
+	}
+
+	standard.Translations = nil
+
+	return standard
+}
+
+func (tag LocationTag) Localized(locale string) LocationTag {
+	if translation, ok := tag.Translations[locale]; ok && translation.Name != "" {
+		tag.Name = translation.Name
+	}
+
+	tag.Translations = nil
+
+	return tag
+}
+
+func (locations LocationMap) Localized(locale string) LocationMap {
+	localized := make(LocationMap, len(locations))
+
+	for slug, location := range locations {
+		localized[slug] = location.Localized(locale)
+	}
+
+	return localized
+}
+
+func (standards LocationStandardMap) Localized(locale string) LocationStandardMap {
+	localized := make(LocationStandardMap, len(standards))
+
+	for slug, standard := range standards {
+		localized[slug] = standard.Localized(locale)
+	}
+
+	return localized
+}
+
+func (tags LocationTagMap) Localized(locale string) LocationTagMap {
+	localized := make(LocationTagMap, len(tags))
+
+	for slug, tag := range tags {
+		localized[slug] = tag.Localized(locale)
+	}
+
+	return localized
+}
+
+// translationFromContentful decodes the text of an entry fetched in a single
+// locale, returning the entry's ID along with it
+func translationFromContentful(contentType string, data []byte) (string, Translation, error) {
+	switch contentType {
+		case "location":
+			var entry contentful.ContentfulLocation
+
+			err := json.Unmarshal(data, &entry)
+
+			if err != nil {
+				return "", Translation{}, &contentful.DecodeError{Err: err}
+			}
+
+			return entry.Sys.ID, Translation{
+				Name: entry.Fields.Name,
+				ShortDescription: entry.Fields.ShortDescription,
+				LongDescription: entry.Fields.LongDescription,
+			}, nil
+		case "standard":
+			var entry contentful.ContentfulLocationStandard
+
+			err := json.Unmarshal(data, &entry)
+
+			if err != nil {
+				return "", Translation{}, &contentful.DecodeError{Err: err}
+			}
+
+			return entry.Sys.ID, Translation{Name: entry.Fields.Title}, nil
+		case "tags":
+			var entry contentful.ContentfulLocationTag
+
+			err := json.Unmarshal(data, &entry)
+
+			if err != nil {
+				return "", Translation{}, &contentful.DecodeError{Err: err}
+			}
+
+			return entry.Sys.ID, Translation{Name: entry.Fields.Title}, nil
+	}
+
+	return "", Translation{}, nil
+}
+
+// fetchTranslations fetches the text of every location, standard and tag in
+// locale, keyed by entry ID
+func (cs *ContentfulSource) fetchTranslations(ctx context.Context, locale string) (map[string]Translation, error) {
+	client := cs.client.ForLocale(locale)
+	translations := map[string]Translation{}
+
+	for _, contentType := range []string{"location", "standard", "tags"} {
+		items, _, err := client.GetAllEntries(ctx, contentType, 0)
+
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s entries in %s: %w", contentType, locale, err)
+		}
+
+		for _, item := range items {
+			id, translation, err := translationFromContentful(contentType, item)
+
+			if err != nil {
+				return nil, fmt.Errorf("decoding %s in %s: %w", contentType, locale, err)
+			}
+
+			translations[id] = translation
+		}
+	}
+
+	return translations, nil
+}
+
+// fetchEntryTranslations fetches the text of a single entry in every locale
+// besides the default
+func (cs *ContentfulSource) fetchEntryTranslations(ctx context.Context, contentType string, id string) (Translations, error) {
+	if len(cs.locales) == 1 {
+		return nil, nil
+	}
+
+	translations := Translations{}
+
+	for _, locale := range cs.locales[1:] {
+		entry, _, err := cs.client.ForLocale(locale).GetEntry(ctx, contentType, id, 0)
+
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s %s in %s: %w", contentType, id, locale, err)
+		}
+
+		_, translation, err := translationFromContentful(contentType, entry)
+
+		if err != nil {
+			return nil, err
+		}
+
+		translations[locale] = translation
+	}
+
+	return translations, nil
+}
+
+// syncTranslations picks the text of every locale besides the default out of
+// an item from the Sync API
+func (cs *ContentfulSource) syncTranslations(item contentful.SyncItem) (Translations, error) {
+	if len(cs.locales) == 1 {
+		return nil, nil
+	}
+
+	translations := Translations{}
+
+	for _, locale := range cs.locales[1:] {
+		data, err := item.Localize(locale, cs.locales[0])
+
+		if err != nil {
+			return nil, err
+		}
+
+		_, translation, err := translationFromContentful(item.ContentTypeID(), data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		translations[locale] = translation
+	}
+
+	return translations, nil
+}
+
+// translate fetches every locale besides the default and adds them to the
+// entries of next. A locale that fails to load keeps the translations next
+// already had for it.
+func (cs *ContentfulSource) translate(ctx context.Context, next *Snapshot) error {
+	if len(cs.locales) == 1 {
+		return nil
+	}
+
+	fetched := map[string]map[string]Translation{}
+	errs := []error{}
+
+	for _, locale := range cs.locales[1:] {
+		translations, err := cs.fetchTranslations(ctx, locale)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		fetched[locale] = translations
+	}
+
+	merge := func(id string, current Translations) Translations {
+		merged := maps.Clone(current)
+
+		if merged == nil {
+			merged = Translations{}
+		}
+
+		for locale, translations := range fetched {
+			translation, ok := translations[id]
+
+			if ok {
+				merged[locale] = translation
+			} else {
+				delete(merged, locale)
+			}
+		}
+
+		return merged
+	}
+
+	next.Standards = maps.Clone(next.Standards)
+
+	for slug, standard := range next.Standards {
+		standard.Translations = merge(standard.ID, standard.Translations)
+		next.Standards[slug] = standard
+	}
+
+	next.Tags = maps.Clone(next.Tags)
+
+	for slug, tag := range next.Tags {
+		tag.Translations = merge(tag.ID, tag.Translations)
+		next.Tags[slug] = tag
+	}
+
+	next.Locations = maps.Clone(next.Locations)
+
+	for slug, location := range next.Locations {
+		location.Translations = merge(location.ID, location.Translations)
+		next.Locations[slug] = location
+	}
+
+	// the standards and tags embedded in locations were copied before they
+	// were translated
+	next.relink(func(links LocationLinks) bool {
+		return true
+	})
+
+	return errors.Join(errs...)
+}
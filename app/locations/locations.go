@@ -1,14 +1,18 @@
 package locations
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	// "time"
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
 
+	"eatingisactivism/app/cdn"
+	"eatingisactivism/app/config"
 	"eatingisactivism/app/contentful"
-
-	"github.com/joho/godotenv"
 )
 
 type Location struct {
@@ -22,6 +26,22 @@ type Location struct {
 	Lng float64 `json:"lng"`
 	Standard LocationStandard `json:"standard"`
 	Tags []LocationTag `json:"tags"`
+	HeroImage *Asset `json:"heroImage,omitempty"`
+	Gallery []Asset `json:"gallery"`
+	// Draft is set in preview mode for a location that was never published
+	Draft bool `json:"draft,omitempty"`
+	Translations Translations `json:"translations,omitempty"`
+	// links is set when the location is built from Contentful so links that
+	// didn't resolve yet are still tracked
+	links *LocationLinks
+}
+
+// LocationLinks are the IDs of the entries a location links to
+type LocationLinks struct {
+	StandardID string `json:"standardId"`
+	TagIDs []string `json:"tagIds"`
+	HeroImageID string `json:"heroImageId,omitempty"`
+	GalleryIDs []string `json:"galleryIds,omitempty"`
 }
 
 type LocationStandard struct {
@@ -29,6 +49,7 @@ type LocationStandard struct {
 	Name string `json:"name"`
 	Slug string `json:"slug"`
 	Icon string `json:"icon"`
+	Translations Translations `json:"translations,omitempty"`
 }
 
 type LocationTag struct {
@@ -36,347 +57,734 @@ type LocationTag struct {
 	Name string `json:"name"`
 	Slug string `json:"slug"`
 	Icon string `json:"icon"`
+	Translations Translations `json:"translations,omitempty"`
+}
+
+// Asset is a file uploaded to Contentful, usually an image
+type Asset struct {
+	ID string `json:"id"`
+	Title string `json:"title"`
+	Description string `json:"description"`
+	URL string `json:"url"`
+	ContentType string `json:"contentType"`
+	Width int `json:"width"`
+	Height int `json:"height"`
 }
 
+// linkDepth is how many levels of links to have Contentful include. Two
+// covers a location's standard and tags as well as entries embedded in its
+// long description and their own links.
+const linkDepth int = 2
+
 type LocationMap map[string]Location
 type LocationStandardMap map[string]LocationStandard
 type LocationTagMap map[string]LocationTag
+type AssetMap map[string]Asset
+type LocationLinksMap map[string]LocationLinks
+
+// ErrNoContentful is returned by HandleWebhook when locations don't come
+// from Contentful
+var ErrNoContentful = errors.New("locations: not loading from Contentful")
+
+// Service holds the locations in memory and keeps them up to date from their
+// source
+type Service struct {
+	store *Store
+	source Source
+	refresher *Refresher
+	// purger removes pages from the CDN once a webhook changed them
+	purger cdn.Purger
+	// locales are the locales content is loaded in, the default first
+	locales []string
+	richText *contentful.RichTextRenderer
+}
 
-var (
-	allLocations LocationMap
-	allStandards LocationStandardMap
-	allTags LocationTagMap
-	contentfulClient *contentful.Contentful
-)
+// New builds the service and its source from cfg. Pages changed by webhooks
+// are purged through purger, which may be nil. Nothing is loaded until Start.
+func New(cfg config.Locations, purger cdn.Purger) (*Service, error) {
+	if purger == nil {
+		purger = cdn.Noop{}
+	}
 
-func init() {
-	godotenv.Load(".env")
+	svc := &Service{
+		store: NewStore(),
+		purger: purger,
+		locales: localeList(cfg.Contentful.Locale, cfg.Contentful.Locales),
+	}
 
-	contentfulApiKey := os.Getenv("CONTENTFUL_API_KEY")
-	contentfulApiBaseUrl := os.Getenv("CONTENTFUL_API_BASE_URL")
-	contentfulSpaceId := os.Getenv("CONTENTFUL_SPACE_ID")
+	switch cfg.Source {
+		case config.SourceContentful:
+			svc.source = NewContentfulSource(cfg.Contentful, svc.store, svc.locales)
+		case config.SourceFixtures:
+			svc.source = NewFixtureSource(cfg.FixturesPath, svc.store)
+		default:
+			return nil, fmt.Errorf("unknown locations source %q", cfg.Source)
+	}
+
+	svc.refresher = NewRefresher(svc.refresh, cfg.RefreshInterval, cfg.RefreshJitter)
+	svc.richText = newRichTextRenderer(svc)
+
+	return svc, nil
+}
 
-	if (contentfulApiKey == "" || contentfulApiBaseUrl == "" || contentfulSpaceId == "") {
-		fmt.Println("Error: missing Contentful API key, base URL, or space ID")
+// Start loads the locations and starts refreshing them in the background. A
+// snapshot on disk is served straight away while the source catches up, so a
+// slow or failing source doesn't hold up boot. Otherwise the first load
+// happens before Start returns, and a failure is logged rather than returned
+// since the refresh keeps trying.
+func (svc *Service) Start(ctx context.Context) {
+	if svc.source.Restore() && svc.refresher.interval > 0 {
+		svc.refresher.StartNow()
 		return
 	}
 
-	allLocations = make(LocationMap)
-	allStandards = make(LocationStandardMap)
-	allTags = make(LocationTagMap)
+	err := svc.refresher.Refresh(ctx)
 
-	contentfulClient = contentful.New(contentfulApiKey, contentfulSpaceId, "master", contentfulApiBaseUrl)
+	if err != nil {
+		slog.Error("loading locations failed", "err", err)
+	}
+
+	svc.refresher.Start()
+}
 
-	buildData()
+// Stop stops the background refresh, waiting for a refresh in flight until
+// ctx is done
+func (svc *Service) Stop(ctx context.Context) error {
+	return svc.refresher.Stop(ctx)
+}
+
+// GetLocations returns all locations. The map is shared and must not be modified.
+func (svc *Service) GetLocations() LocationMap {
+	return svc.store.Locations()
+}
 
-	// go Poll()
+func (svc *Service) GetStandards() LocationStandardMap {
+	return svc.store.Standards()
 }
 
-// GetLocations returns all locations
-func GetLocations() LocationMap {
-	return allLocations
+func (svc *Service) GetTags() LocationTagMap {
+	return svc.store.Tags()
 }
 
-func GetStandards() LocationStandardMap {
-	return allStandards
+// Version returns the version of the data, which goes up whenever it changes,
+// and when it last changed
+func (svc *Service) Version() (uint64, time.Time) {
+	snapshot := svc.store.Snapshot()
+
+	return snapshot.Version, snapshot.UpdatedAt
 }
 
-func GetTags() LocationTagMap {
-	return allTags
+// GetRefreshStatus reports on the background refresh from the source
+func (svc *Service) GetRefreshStatus() RefreshStatus {
+	return svc.refresher.Status()
 }
 
-// PollLocations polls the Google Sheets API for new locations every 5 seconds
-// func poll() {
-// 	for {
-// 		buildData()
+// Preview reports whether locations are loaded from the Preview API, drafts
+// included
+func (svc *Service) Preview() bool {
+	source, ok := svc.source.(*ContentfulSource)
 
-// 		time.Sleep(10 * time.Second)
-// 	}
-// }
+	return ok && source.Preview()
+}
 
-func buildData() {
-	newStandards := ContentfulStandards()
+// HandleWebhook applies a webhook from Contentful and purges the pages it
+// changed from the CDN. An error means the change wasn't applied and the
+// webhook is worth retrying. A failed purge is only logged, the change is
+// live and the CDN catches up once its copy expires.
+func (svc *Service) HandleWebhook(ctx context.Context, webhookType string, data []byte) error {
+	source, ok := svc.source.(*ContentfulSource)
 
-	if len(newStandards) > 0 {
-		AddStandards(newStandards)
+	if !ok {
+		return ErrNoContentful
 	}
 
-	newTags := ContentfulTags()
+	// the slug may change or the location may go away, so the pages to purge
+	// are looked up before and after
+	var webhook contentful.ContentfulWebhook
+
+	json.Unmarshal(data, &webhook)
+	slugs := svc.affectedSlugs(webhook.Sys.ID)
 
-	if len(newTags) > 0 {
-		AddTags(newTags)
+	err := source.HandleWebhook(ctx, webhookType, data)
+
+	if err != nil {
+		return err
 	}
 
-	newLocations := ContentfulLocations()
+	for _, slug := range svc.affectedSlugs(webhook.Sys.ID) {
+		if !slices.Contains(slugs, slug) {
+			slugs = append(slugs, slug)
+		}
+	}
 
+	err = svc.purge(ctx, slugs)
 
-	if len(newLocations) > 0 {
-		AddLocations(newLocations)
+	if err != nil {
+		slog.Warn("purging the CDN failed", "entryID", webhook.Sys.ID, "err", err)
 	}
+
+	return nil
 }
 
-func removeEntry(contentType string, id string) {
-	switch contentType {
-		case "location":
-			location := GetLocationByID(id)
-			delete(allLocations, location.Slug)
-		case "standard":
-			standard := GetStandardByID(id)
-			delete(allStandards, standard.Slug)
-		case "tags":
-			tag := GetTagByID(id)
-			delete(allTags, tag.Slug)
+// buildData rebuilds everything from Contentful into a new snapshot and swaps
+// it in, so readers never see a half built set of maps. Anything that failed to
+// load keeps its current data and its error is returned. Anything that loaded
+// replaces the current data outright, even when Contentful has none left.
+func (cs *ContentfulSource) buildData(ctx context.Context) error {
+	return cs.swapRebuilt(ctx, func(base Snapshot) (*Snapshot, error) {
+		return cs.rebuild(ctx, base)
+	})
+}
+
+// rebuild builds the next snapshot on top of base
+func (cs *ContentfulSource) rebuild(ctx context.Context, base Snapshot) (*Snapshot, error) {
+	next := base
+
+	newStandards, standardsErr := cs.ContentfulStandards(ctx)
+
+	if standardsErr == nil {
+		next.Standards = LocationStandardMap{}
+
+		for _, standard := range newStandards {
+			next.Standards[standard.Slug] = standard
+		}
+	}
+
+	newTags, tagsErr := cs.ContentfulTags(ctx)
+
+	if tagsErr == nil {
+		next.Tags = LocationTagMap{}
+
+		for _, tag := range newTags {
+			next.Tags[tag.Slug] = tag
+		}
+	}
+
+	newLocations, graph, locationsErr := cs.fetchLocations(ctx, next)
+
+	if locationsErr == nil {
+		next.Locations = LocationMap{}
+		next.Links = LocationLinksMap{}
+
+		for _, location := range newLocations {
+			next.Locations[location.Slug] = location
+			next.Links[location.ID] = location.linkIDs()
+		}
+	}
+
+	assets := assetsFromGraph(graph)
+
+	if len(assets) > 0 {
+		next.Assets = maps.Clone(next.Assets)
+
+		for _, asset := range assets {
+			next.Assets[asset.ID] = asset
+		}
 	}
+
+	translationsErr := cs.translate(ctx, &next)
+
+	return &next, errors.Join(standardsErr, tagsErr, locationsErr, translationsErr)
 }
 
-func getEntry(contentType string, id string) {
+// removeEntry drops an entry from the store. Deletions from the Sync API
+// don't say what content type they were, so an empty contentType looks in
+// every map.
+func removeEntry(s *Store, contentType string, id string) {
 	switch contentType {
 		case "location":
-			location := ContentfulLocation(id)
-			allLocations[location.Slug] = location
+			s.DeleteLocation(id)
 		case "standard":
-			standard := ContentfulStandard(id)
-			allStandards[standard.Slug] = standard
+			s.DeleteStandard(id)
 		case "tags":
-			tag := ContentfulTag(id)
-			allTags[tag.Slug] = tag
+			s.DeleteTag(id)
+		case "":
+			s.DeleteLocation(id)
+			s.DeleteStandard(id)
+			s.DeleteTag(id)
 	}
 }
 
-func ContentfulLocations() []Location {
-	locations := []Location{}
+// getEntry fetches a single entry and applies it to the store
+func (cs *ContentfulSource) getEntry(ctx context.Context, contentType string, id string) error {
+	switch contentType {
+		case "location", "standard", "tags":
+		default:
+			return nil
+	}
 
-	entriesResponse, err := contentfulClient.GetEntries("location", 1000, 0, "")
+	entry, graph, err := cs.client.GetEntry(ctx, contentType, id, linkDepth)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
-		return locations
+		return fmt.Errorf("fetching %s %s: %w", contentType, id, err)
 	}
 
-	var response contentful.ContentfulLocationResponse
-
-	err = json.Unmarshal(entriesResponse, &response)
+	translations, err := cs.fetchEntryTranslations(ctx, contentType, id)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
+		return err
 	}
 
-	for _, location := range response.Items {
-		standard := GetStandardByID(location.Fields.Standard.Sys.ID)
-		tags := []LocationTag{}
+	for _, asset := range assetsFromGraph(graph) {
+		cs.store.PutAsset(asset)
+	}
 
-		for _, tag := range location.Fields.Tags {
-			foundTag := GetTagByID(tag.Sys.ID)
+	if contentType != "location" || !cs.Preview() {
+		return applyEntry(cs.store, contentType, entry, graph, translations)
+	}
 
-			tags = append(tags, foundTag)
-		}
+	_, _, err = cs.delivery.GetEntry(ctx, contentType, id, 0)
 
-		locations = append(locations, Location{
-			ID: location.Sys.ID,
-			Name: location.Fields.Name,
-			Slug: location.Fields.Slug,
-			Url: location.Fields.Url,
-			ShortDescription: location.Fields.ShortDescription,
-			LongDescription: location.Fields.LongDescription,
-			Lat: location.Fields.Coordinates.Lat,
-			Lng: location.Fields.Coordinates.Lng,
-			Standard: standard,
-			Tags: tags,
-		})
+	if err != nil && !errors.Is(err, contentful.ErrNotFound) {
+		return fmt.Errorf("checking whether %s %s is published: %w", contentType, id, err)
 	}
 
-	return locations
+	draft := err != nil
+	err = applyEntry(cs.store, contentType, entry, graph, translations)
+
+	if err != nil {
+		return err
+	}
+
+	cs.store.SetDraft(id, draft)
+
+	return nil
 }
 
-func ContentfulLocation(id string) Location {
-	location := Location{}
+// applyEntry decodes a single entry and puts it in the store. It is shared by
+// webhooks, which fetch the entry, and the Sync API, which hands it over.
+// Links are resolved through graph first and the store second, graph may be
+// nil. translations holds the entry's text in the other locales.
+func applyEntry(s *Store, contentType string, data []byte, graph *contentful.LinkGraph, translations Translations) error {
+	switch contentType {
+		case "location":
+			var response contentful.ContentfulLocation
+
+			err := json.Unmarshal(data, &response)
+
+			if err != nil {
+				return &contentful.DecodeError{Err: err}
+			}
+
+			location := locationFromContentful(response, graph, s.Snapshot())
+			location.Translations = translations
+			s.PutLocation(location)
+		case "standard":
+			var response contentful.ContentfulLocationStandard
 
-	entriesResponse, err := contentfulClient.GetEntries("location", 1000, 0, id)
+			err := json.Unmarshal(data, &response)
+
+			if err != nil {
+				return &contentful.DecodeError{Err: err}
+			}
+
+			standard := standardFromContentful(response)
+			standard.Translations = translations
+			s.PutStandard(standard)
+		case "tags":
+			var response contentful.ContentfulLocationTag
+
+			err := json.Unmarshal(data, &response)
+
+			if err != nil {
+				return &contentful.DecodeError{Err: err}
+			}
+
+			tag := tagFromContentful(response)
+			tag.Translations = translations
+			s.PutTag(tag)
+	}
+
+	return nil
+}
+
+func (cs *ContentfulSource) getAsset(ctx context.Context, id string) error {
+	assetResponse, err := cs.client.GetAsset(ctx, id)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
-		return location
+		return fmt.Errorf("fetching asset %s: %w", id, err)
 	}
 
-	var response contentful.ContentfulLocation
+	return applyAsset(cs.store, assetResponse)
+}
+
+func applyAsset(s *Store, data []byte) error {
+	var response contentful.ContentfulAsset
 
-	err = json.Unmarshal(entriesResponse, &response)
+	err := json.Unmarshal(data, &response)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
+		return &contentful.DecodeError{Err: err}
 	}
 
-	if (response.Sys.ID == "") {
-		return location
+	s.PutAsset(assetFromContentful(response))
+
+	return nil
+}
+
+// locationFromContentful builds a location, resolving its standard and tags
+// through graph when it has them and snapshot otherwise. Tags that can't be
+// resolved either way are left out rather than added empty.
+func locationFromContentful(entry contentful.ContentfulLocation, graph *contentful.LinkGraph, snapshot Snapshot) Location {
+	if entry.Sys.ID == "" {
+		return Location{}
 	}
 
-	standard := GetStandardByID(response.Fields.Standard.Sys.ID)
+	standard, _ := resolveStandard(entry.Fields.Standard, graph, snapshot)
 	tags := []LocationTag{}
+	links := &LocationLinks{
+		StandardID: entry.Fields.Standard.Sys.ID,
+		TagIDs: []string{},
+		HeroImageID: entry.Fields.HeroImage.Sys.ID,
+		GalleryIDs: []string{},
+	}
 
-	for _, tag := range response.Fields.Tags {
-		tags = append(tags, GetTagByID(tag.Sys.ID))
+	var heroImage *Asset
+
+	if asset, ok := resolveAsset(entry.Fields.HeroImage, graph, snapshot); ok {
+		heroImage = &asset
+	}
+
+	gallery := []Asset{}
+
+	for _, link := range entry.Fields.Gallery {
+		links.GalleryIDs = append(links.GalleryIDs, link.Sys.ID)
+
+		asset, ok := resolveAsset(link, graph, snapshot)
+
+		if ok {
+			gallery = append(gallery, asset)
+		}
 	}
 
-	location = Location{
-		ID: response.Sys.ID,
-		Name: response.Fields.Name,
-		Slug: response.Fields.Slug,
-		Url: response.Fields.Url,
-		ShortDescription: response.Fields.ShortDescription,
-		LongDescription: response.Fields.LongDescription,
-		Lat: response.Fields.Coordinates.Lat,
-		Lng: response.Fields.Coordinates.Lng,
+	for _, link := range entry.Fields.Tags {
+		links.TagIDs = append(links.TagIDs, link.Sys.ID)
+
+		tag, ok := resolveTag(link, graph, snapshot)
+
+		if ok {
+			tags = append(tags, tag)
+		}
+	}
+
+	return Location{
+		ID: entry.Sys.ID,
+		Name: entry.Fields.Name,
+		Slug: entry.Fields.Slug,
+		Url: entry.Fields.Url,
+		ShortDescription: entry.Fields.ShortDescription,
+		LongDescription: entry.Fields.LongDescription,
+		Lat: entry.Fields.Coordinates.Lat,
+		Lng: entry.Fields.Coordinates.Lng,
 		Standard: standard,
 		Tags: tags,
+		HeroImage: heroImage,
+		Gallery: gallery,
+		links: links,
 	}
+}
 
-	return location
+// linkIDs returns the links the location was built from, or for a location
+// that wasn't built from Contentful, the links of its standard and tags
+func (location Location) linkIDs() LocationLinks {
+	if location.links != nil {
+		return *location.links
+	}
+
+	links := LocationLinks{
+		StandardID: location.Standard.ID,
+		TagIDs: []string{},
+		GalleryIDs: []string{},
+	}
+
+	for _, tag := range location.Tags {
+		links.TagIDs = append(links.TagIDs, tag.ID)
+	}
+
+	if location.HeroImage != nil {
+		links.HeroImageID = location.HeroImage.ID
+	}
+
+	for _, asset := range location.Gallery {
+		links.GalleryIDs = append(links.GalleryIDs, asset.ID)
+	}
+
+	return links
 }
 
-func ContentfulStandards() []LocationStandard {
-	standards := []LocationStandard{}
+func (links LocationLinks) hasTag(id string) bool {
+	return slices.Contains(links.TagIDs, id)
+}
 
-	entriesResponse, err := contentfulClient.GetEntries("standard", 1000, 0, "")
+func (links LocationLinks) hasAsset(id string) bool {
+	return links.HeroImageID == id || slices.Contains(links.GalleryIDs, id)
+}
 
-	if err != nil {
-		fmt.Println("Error: ", err)
-		return standards
+func resolveStandard(link contentful.ContentfulResponseLink, graph *contentful.LinkGraph, snapshot Snapshot) (LocationStandard, bool) {
+	var entry contentful.ContentfulLocationStandard
+
+	if graph.ResolveInto(link, &entry) {
+		standard := standardFromContentful(entry)
+
+		// the graph only holds the default locale
+		if known, ok := snapshot.StandardByID(standard.ID); ok {
+			standard.Translations = known.Translations
+		}
+
+		return standard, true
 	}
 
-	var response contentful.ContentfulLocationStandardResponse
+	return snapshot.StandardByID(link.Sys.ID)
+}
 
-	err = json.Unmarshal(entriesResponse, &response)
+func resolveTag(link contentful.ContentfulResponseLink, graph *contentful.LinkGraph, snapshot Snapshot) (LocationTag, bool) {
+	var entry contentful.ContentfulLocationTag
 
-	if err != nil {
-		fmt.Println("Error: ", err)
+	if graph.ResolveInto(link, &entry) {
+		tag := tagFromContentful(entry)
+
+		// the graph only holds the default locale
+		if known, ok := snapshot.TagByID(tag.ID); ok {
+			tag.Translations = known.Translations
+		}
+
+		return tag, true
 	}
 
-	for _, standard := range response.Items {
-		standards = append(standards, LocationStandard{
-			ID: standard.Sys.ID,
-			Name: standard.Fields.Title,
-			Slug: standard.Fields.Slug,
-			Icon: standard.Fields.Icon,
-		})
+	return snapshot.TagByID(link.Sys.ID)
+}
+
+// assetsFromGraph converts every asset included in a response
+func assetsFromGraph(graph *contentful.LinkGraph) []Asset {
+	assets := []Asset{}
+
+	for _, raw := range graph.Assets() {
+		var asset contentful.ContentfulAsset
+
+		if json.Unmarshal(raw, &asset) == nil && asset.Sys.ID != "" {
+			assets = append(assets, assetFromContentful(asset))
+		}
 	}
 
-	return standards
+	return assets
 }
 
-func ContentfulStandard(id string) LocationStandard {
-	standard := LocationStandard{}
+func standardFromContentful(entry contentful.ContentfulLocationStandard) LocationStandard {
+	if entry.Sys.ID == "" {
+		return LocationStandard{}
+	}
 
-	entriesResponse, err := contentfulClient.GetEntries("standard", 1000, 0, id)
+	return LocationStandard{
+		ID: entry.Sys.ID,
+		Name: entry.Fields.Title,
+		Slug: entry.Fields.Slug,
+		Icon: entry.Fields.Icon,
+	}
+}
 
-	if err != nil {
-		fmt.Println("Error: ", err)
-		return standard
+func tagFromContentful(entry contentful.ContentfulLocationTag) LocationTag {
+	if entry.Sys.ID == "" {
+		return LocationTag{}
 	}
 
-	var response contentful.ContentfulLocationStandard
+	return LocationTag{
+		ID: entry.Sys.ID,
+		Name: entry.Fields.Title,
+		Slug: entry.Fields.Slug,
+		Icon: entry.Fields.Icon,
+	}
+}
+
+func assetFromContentful(asset contentful.ContentfulAsset) Asset {
+	if asset.Sys.ID == "" {
+		return Asset{}
+	}
+
+	url := asset.Fields.File.URL
+
+	// Contentful hands out protocol relative URLs
+	if len(url) > 2 && url[:2] == "//" {
+		url = "https:" + url
+	}
+
+	return Asset{
+		ID: asset.Sys.ID,
+		Title: asset.Fields.Title,
+		Description: asset.Fields.Description,
+		URL: url,
+		ContentType: asset.Fields.File.ContentType,
+		Width: asset.Fields.File.Details.Image.Width,
+		Height: asset.Fields.File.Details.Image.Height,
+	}
+}
+
+// ContentfulLocations fetches every location, linking their standard and
+// tags against the store where Contentful didn't include them
+func (cs *ContentfulSource) ContentfulLocations(ctx context.Context) ([]Location, error) {
+	locations, _, err := cs.fetchLocations(ctx, cs.store.Snapshot())
 
-	err = json.Unmarshal(entriesResponse, &response)
+	return locations, err
+}
+
+// fetchLocations fetches all locations along with their linked entries and
+// assets. Links missing from the includes fall back to snapshot. Nothing is
+// returned on an error, so a partial list is never mistaken for all of them.
+func (cs *ContentfulSource) fetchLocations(ctx context.Context, snapshot Snapshot) ([]Location, *contentful.LinkGraph, error) {
+	locations := []Location{}
+
+	items, graph, err := cs.client.GetAllEntries(ctx, "location", linkDepth)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
+		return nil, nil, fmt.Errorf("fetching locations: %w", err)
 	}
 
-	if (response.Sys.ID == "") {
-		return standard
+	for _, item := range items {
+		var entry contentful.ContentfulLocation
+
+		err = json.Unmarshal(item, &entry)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding location: %w", &contentful.DecodeError{Err: err})
+		}
+
+		locations = append(locations, locationFromContentful(entry, graph, snapshot))
 	}
 
-	standard = LocationStandard{
-		ID: response.Sys.ID,
-		Name: response.Fields.Title,
-		Slug: response.Fields.Slug,
-		Icon: response.Fields.Icon,
+	// without knowing which are drafts, every location would show as
+	// published
+	if cs.Preview() {
+		err = cs.markDrafts(ctx, locations)
+
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return standard
+	return locations, graph, nil
 }
 
-func ContentfulTags() []LocationTag {
-	tags := []LocationTag{}
+func (cs *ContentfulSource) ContentfulLocation(ctx context.Context, id string) (Location, error) {
+	entryResponse, graph, err := cs.client.GetEntry(ctx, "location", id, linkDepth)
+
+	if err != nil {
+		return Location{}, err
+	}
 
-	entriesResponse, err := contentfulClient.GetEntries("tags", 1000, 0, "")
+	var response contentful.ContentfulLocation
+
+	err = json.Unmarshal(entryResponse, &response)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
-		return tags
+		return Location{}, &contentful.DecodeError{Err: err}
 	}
 
-	var response contentful.ContentfulLocationTagResponse
+	return locationFromContentful(response, graph, cs.store.Snapshot()), nil
+}
 
-	err = json.Unmarshal(entriesResponse, &response)
+func (cs *ContentfulSource) ContentfulStandards(ctx context.Context) ([]LocationStandard, error) {
+	standards := []LocationStandard{}
+
+	items, _, err := cs.client.GetAllEntries(ctx, "standard", 0)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
+		return nil, fmt.Errorf("fetching standards: %w", err)
 	}
 
-	for _, tag := range response.Items {
-		tags = append(tags, LocationTag{
-			ID: tag.Sys.ID,
-			Name: tag.Fields.Title,
-			Slug: tag.Fields.Slug,
-			Icon: tag.Fields.Icon,
-		})
+	for _, item := range items {
+		var entry contentful.ContentfulLocationStandard
+
+		err = json.Unmarshal(item, &entry)
+
+		if err != nil {
+			return nil, fmt.Errorf("decoding standard: %w", &contentful.DecodeError{Err: err})
+		}
+
+		standards = append(standards, standardFromContentful(entry))
 	}
 
-	return tags
+	return standards, nil
 }
 
-func ContentfulTag(id string) LocationTag {
-	tag := LocationTag{}
+func (cs *ContentfulSource) ContentfulStandard(ctx context.Context, id string) (LocationStandard, error) {
+	entryResponse, _, err := cs.client.GetEntry(ctx, "standard", id, 0)
 
-	entriesResponse, err := contentfulClient.GetEntries("tags", 1000, 0, id)
+	if err != nil {
+		return LocationStandard{}, err
+	}
+
+	var response contentful.ContentfulLocationStandard
+
+	err = json.Unmarshal(entryResponse, &response)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
-		return tag
+		return LocationStandard{}, &contentful.DecodeError{Err: err}
 	}
 
-	var response contentful.ContentfulLocationTag
+	return standardFromContentful(response), nil
+}
+
+func (cs *ContentfulSource) ContentfulTags(ctx context.Context) ([]LocationTag, error) {
+	tags := []LocationTag{}
 
-	err = json.Unmarshal(entriesResponse, &response)
+	items, _, err := cs.client.GetAllEntries(ctx, "tags", 0)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
+		return nil, fmt.Errorf("fetching tags: %w", err)
 	}
 
-	if (response.Sys.ID == "") {
-		return tag
+	for _, item := range items {
+		var entry contentful.ContentfulLocationTag
+
+		err = json.Unmarshal(item, &entry)
+
+		if err != nil {
+			return nil, fmt.Errorf("decoding tag: %w", &contentful.DecodeError{Err: err})
+		}
+
+		tags = append(tags, tagFromContentful(entry))
 	}
 
-	tag = LocationTag{
-		ID: response.Sys.ID,
-		Name: response.Fields.Title,
-		Slug: response.Fields.Slug,
-		Icon: response.Fields.Icon,
+	return tags, nil
+}
+
+func (cs *ContentfulSource) ContentfulTag(ctx context.Context, id string) (LocationTag, error) {
+	entryResponse, _, err := cs.client.GetEntry(ctx, "tags", id, 0)
+
+	if err != nil {
+		return LocationTag{}, err
 	}
 
-	return tag
+	var response contentful.ContentfulLocationTag
+
+	err = json.Unmarshal(entryResponse, &response)
+
+	if err != nil {
+		return LocationTag{}, &contentful.DecodeError{Err: err}
+	}
+
+	return tagFromContentful(response), nil
 }
 
-func AddLocations(locations []Location) {
+func (svc *Service) AddLocations(locations []Location) {
 	for _, location := range locations {
-		allLocations[location.Slug] = location
+		svc.store.PutLocation(location)
 	}
 }
 
-func AddStandards(standards []LocationStandard) {
+func (svc *Service) AddStandards(standards []LocationStandard) {
 	for _, standard := range standards {
-		allStandards[standard.Slug] = standard
+		svc.store.PutStandard(standard)
 	}
 }
 
-func AddTags(tags []LocationTag) {
+func (svc *Service) AddTags(tags []LocationTag) {
 	for _, tag := range tags {
-		allTags[tag.Slug] = tag
+		svc.store.PutTag(tag)
 	}
 }
 
-func GetLocationBySlug(slug string) Location {
-	location, ok := allLocations[slug]
+func (svc *Service) GetLocationBySlug(slug string) Location {
+	location, ok := svc.store.Locations()[slug]
 
 	if ok {
 		return location
@@ -385,8 +793,8 @@ func GetLocationBySlug(slug string) Location {
 	return Location{}
 }
 
-func GetStandardBySlug(slug string) LocationStandard {
-	standard, ok := allStandards[slug]
+func (svc *Service) GetStandardBySlug(slug string) LocationStandard {
+	standard, ok := svc.store.Standards()[slug]
 
 	if ok {
 		return standard
@@ -395,8 +803,8 @@ func GetStandardBySlug(slug string) LocationStandard {
 	return LocationStandard{}
 }
 
-func GetTagBySlug(slug string) LocationTag {
-	tag, ok := allTags[slug]
+func (svc *Service) GetTagBySlug(slug string) LocationTag {
+	tag, ok := svc.store.Tags()[slug]
 
 	if ok {
 		return tag
@@ -405,67 +813,67 @@ func GetTagBySlug(slug string) LocationTag {
 	return LocationTag{}
 }
 
-func GetLocationByID(id string) Location {
-	for _, location := range allLocations {
-		if location.ID == id {
-			return location
-		}
-	}
+func (svc *Service) GetLocationByID(id string) Location {
+	location, _ := svc.store.Snapshot().LocationByID(id)
 
-	return Location{}
+	return location
 }
 
-func GetTagByID(id string) LocationTag {
-	for _, tag := range allTags {
-		if tag.ID == id {
-			return tag
-		}
-	}
+func (svc *Service) GetTagByID(id string) LocationTag {
+	tag, _ := svc.store.Snapshot().TagByID(id)
 
-	return LocationTag{}
+	return tag
 }
 
-func GetStandardByID(id string) LocationStandard {
-	for _, standard := range allStandards {
-		if standard.ID == id {
-			return standard
-		}
-	}
+func (svc *Service) GetStandardByID(id string) LocationStandard {
+	standard, _ := svc.store.Snapshot().StandardByID(id)
 
-	return LocationStandard{}
+	return standard
 }
 
-func HandleWebhook(webhookType string, data []byte) {
+// HandleWebhook applies a webhook from Contentful to the store. An error
+// means the change wasn't applied and the webhook is worth retrying.
+func (cs *ContentfulSource) HandleWebhook(ctx context.Context, webhookType string, data []byte) error {
 	var webhook contentful.ContentfulWebhook
 
 	err := json.Unmarshal(data, &webhook)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
-		return
+		return &contentful.DecodeError{Err: err}
 	}
 
 	entryID := webhook.Sys.ID
+	contentType := webhook.Sys.ContentType.Sys.ID
+
+	slog.Info("handling webhook", "topic", webhookType, "entryID", entryID, "contentType", contentType)
+
+	// a preview keeps showing unpublished entries as drafts
+	if cs.Preview() {
+		switch webhookType {
+		case contentful.WebhookSave, contentful.WebhookAutoSave, contentful.WebhookUnpublish:
+			return cs.getEntry(ctx, contentType, entryID)
+		}
+	}
 
 	switch webhookType {
-	case contentful.WebhookPublish:
-		getEntry(webhook.Sys.ContentType.Sys.ID, entryID)
-	case contentful.WebhookUnarchive:
-		getEntry(webhook.Sys.ContentType.Sys.ID, entryID)
-	case contentful.WebhookUnpublish:
-		removeEntry(webhook.Sys.ContentType.Sys.ID, entryID)
-	case contentful.WebhookArchive:
-		removeEntry(webhook.Sys.ContentType.Sys.ID, entryID)
-	case contentful.WebhookDelete:
-		removeEntry(webhook.Sys.ContentType.Sys.ID, entryID)
+	case contentful.WebhookPublish, contentful.WebhookUnarchive:
+		return cs.getEntry(ctx, contentType, entryID)
+	case contentful.WebhookUnpublish, contentful.WebhookArchive, contentful.WebhookDelete:
+		removeEntry(cs.store, contentType, entryID)
+	case contentful.WebhookAssetPublish, contentful.WebhookAssetUnarchive:
+		return cs.getAsset(ctx, entryID)
+	case contentful.WebhookAssetUnpublish, contentful.WebhookAssetArchive, contentful.WebhookAssetDelete:
+		cs.store.DeleteAsset(entryID)
 	}
+
+	return nil
 }
 
 // filter function that return locations based on Standards, and Tags
-func FilterLocations(standards []string, tags []string) LocationMap {
+func (svc *Service) FilterLocations(standards []string, tags []string) LocationMap {
 	locations := LocationMap{}
 
-	for _, location := range allLocations {
+	for _, location := range svc.store.Locations() {
 		if len(standards) > 0 && !string_in_array(location.Standard.Slug, standards) {
 			continue
 		}
@@ -0,0 +1,162 @@
+package locations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// snapshotVersion is bumped whenever the shape of a snapshot file changes, so
+// a file written by an older build is ignored rather than half read
+const snapshotVersion int = 1
+
+// snapshotFile is what's written to disk
+type snapshotFile struct {
+	Version int `json:"version"`
+	SavedAt time.Time `json:"savedAt"`
+	// Locales and Preview are what the snapshot was loaded with. A delta sync
+	// wouldn't fill in a locale that was added since, so a snapshot loaded
+	// differently is ignored.
+	Locales []string `json:"locales"`
+	Preview bool `json:"preview"`
+	Locations LocationMap `json:"locations"`
+	Standards LocationStandardMap `json:"standards"`
+	Tags LocationTagMap `json:"tags"`
+	Assets AssetMap `json:"assets"`
+	Links LocationLinksMap `json:"links"`
+	SyncToken string `json:"syncToken"`
+}
+
+// saveSnapshot writes snapshot, loaded in locales, to path. It writes to a
+// temporary file first and renames it into place, so a crash mid write leaves
+// the old file intact.
+func saveSnapshot(path string, snapshot Snapshot, locales []string, preview bool) error {
+	data, err := json.Marshal(snapshotFile{
+		Version: snapshotVersion,
+		SavedAt: time.Now(),
+		Locales: locales,
+		Preview: preview,
+		Locations: snapshot.Locations,
+		Standards: snapshot.Standards,
+		Tags: snapshot.Tags,
+		Assets: snapshot.Assets,
+		Links: snapshot.Links,
+		SyncToken: snapshot.SyncToken,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+
+	err = os.MkdirAll(dir, 0o755)
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path) + ".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadSnapshot reads a snapshot written by saveSnapshot, as long as it was
+// loaded in the same locales and preview setting. A missing file returns an
+// error matching fs.ErrNotExist.
+func loadSnapshot(path string, locales []string, preview bool) (Snapshot, time.Time, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return Snapshot{}, time.Time{}, err
+	}
+
+	var file snapshotFile
+
+	err = json.Unmarshal(data, &file)
+
+	if err != nil {
+		return Snapshot{}, time.Time{}, fmt.Errorf("decoding snapshot %s: %w", path, err)
+	}
+
+	if file.Version != snapshotVersion {
+		return Snapshot{}, time.Time{}, fmt.Errorf("snapshot %s is version %d, want %d", path, file.Version, snapshotVersion)
+	}
+
+	if !slices.Equal(file.Locales, locales) || file.Preview != preview {
+		return Snapshot{}, time.Time{}, fmt.Errorf("snapshot %s was loaded with other locales or preview setting", path)
+	}
+
+	return Snapshot{
+		Locations: file.Locations,
+		Standards: file.Standards,
+		Tags: file.Tags,
+		Assets: file.Assets,
+		Links: file.Links,
+		SyncToken: file.SyncToken,
+	}, file.SavedAt, nil
+}
+
+// restoreSnapshot puts the snapshot on disk in the store, reporting whether
+// there was one to restore
+func (cs *ContentfulSource) restoreSnapshot() bool {
+	if cs.snapshotPath == "" {
+		return false
+	}
+
+	snapshot, savedAt, err := loadSnapshot(cs.snapshotPath, cs.locales, cs.Preview())
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+
+	if err != nil {
+		slog.Warn("ignoring snapshot", "path", cs.snapshotPath, "err", err)
+		return false
+	}
+
+	cs.store.Swap(snapshot)
+	slog.Info("restored snapshot", "path", cs.snapshotPath, "savedAt", savedAt, "locations", len(snapshot.Locations))
+
+	return true
+}
+
+// persistSnapshot writes the store to disk, logging rather than returning a
+// failure since the live data is fine either way
+func (cs *ContentfulSource) persistSnapshot() {
+	if cs.snapshotPath == "" {
+		return
+	}
+
+	err := saveSnapshot(cs.snapshotPath, cs.store.Snapshot(), cs.locales, cs.Preview())
+
+	if err != nil {
+		slog.Warn("saving snapshot failed", "path", cs.snapshotPath, "err", err)
+	}
+}
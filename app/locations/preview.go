@@ -0,0 +1,46 @@
+package locations
+
+import (
+	"context"
+	"fmt"
+
+	"eatingisactivism/app/config"
+	"eatingisactivism/app/contentful"
+)
+
+// Preview reports whether locations are loaded from the Preview API, drafts
+// included
+func (cs *ContentfulSource) Preview() bool {
+	return cs.client != nil && cs.client.Preview()
+}
+
+// previewClient returns a Preview API client for cfg
+func previewClient(cfg config.Contentful, options []contentful.Option) *contentful.Contentful {
+	if cfg.PreviewBaseURL != "" {
+		return contentful.New(cfg.PreviewAPIKey, cfg.SpaceID, cfg.PreviewBaseURL, append(options, contentful.WithPreview())...)
+	}
+
+	return contentful.NewPreview(cfg.PreviewAPIKey, cfg.SpaceID, options...)
+}
+
+// markDrafts flags the locations the Delivery API doesn't have, which are the
+// ones that were never published
+func (cs *ContentfulSource) markDrafts(ctx context.Context, locations []Location) error {
+	ids, err := cs.delivery.EntryIDs(ctx, "location")
+
+	if err != nil {
+		return fmt.Errorf("fetching published locations: %w", err)
+	}
+
+	published := make(map[string]bool, len(ids))
+
+	for _, id := range ids {
+		published[id] = true
+	}
+
+	for i := range locations {
+		locations[i].Draft = !published[locations[i].ID]
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"slices"
+
+	"eatingisactivism/app/cdn"
+)
+
+// listPaths are the pages that list every location
+var listPaths = []string{"/", "/locations", "/api/v1/locations"}
+
+// affectedSlugs returns the slugs of the locations that are, or link to, the
+// entry or asset with ID id
+func (svc *Service) affectedSlugs(id string) []string {
+	slugs := []string{}
+
+	if id == "" {
+		return slugs
+	}
+
+	for slug, location := range svc.store.Locations() {
+		links := location.linkIDs()
+
+		if location.ID == id || links.StandardID == id || links.hasTag(id) || links.hasAsset(id) {
+			slugs = append(slugs, slug)
+		}
+	}
+
+	return slugs
+}
+
+// purge removes the pages of the locations with slugs from the CDN, along
+// with every page that lists locations. Pages are purged by URL and by cache
+// tag, so variants of a page with a query string go too.
+func (svc *Service) purge(ctx context.Context, slugs []string) error {
+	urls := slices.Clone(listPaths)
+	tags := []string{cdn.TagLocations}
+
+	for _, slug := range slugs {
+		urls = append(urls, "/locations/" + slug)
+		tags = append(tags, cdn.LocationTag(slug))
+	}
+
+	return errors.Join(svc.purger.PurgeURLs(ctx, urls), svc.purger.PurgeTags(ctx, tags))
+}
+
+// refresh refreshes from the source and purges the locations it changed from
+// the CDN. Webhooks purge their own changes, this catches the ones a missed
+// webhook would have made. The first load has nothing cached to purge.
+func (svc *Service) refresh(ctx context.Context) error {
+	before := svc.store.Snapshot()
+	err := svc.source.Refresh(ctx)
+	after := svc.store.Snapshot()
+
+	if before.Version == 0 || after.Version == before.Version {
+		return err
+	}
+
+	slugs := changedSlugs(before.Locations, after.Locations)
+
+	// a full rebuild bumps the version even when nothing changed, the list
+	// pages only need purging when something they show did
+	if len(slugs) == 0 && reflect.DeepEqual(before.Standards, after.Standards) && reflect.DeepEqual(before.Tags, after.Tags) {
+		return err
+	}
+
+	purgeErr := svc.purge(ctx, slugs)
+
+	if purgeErr != nil {
+		slog.Warn("purging the CDN after a refresh failed", "err", purgeErr)
+	}
+
+	return err
+}
+
+// changedSlugs returns the slugs of the locations added, removed or changed
+// between before and after. A renamed standard, tag or image changes the
+// locations linking to it as well.
+func changedSlugs(before LocationMap, after LocationMap) []string {
+	slugs := []string{}
+
+	for slug, location := range before {
+		if other, ok := after[slug]; !ok || !reflect.DeepEqual(location, other) {
+			slugs = append(slugs, slug)
+		}
+	}
+
+	for slug := range after {
+		if _, ok := before[slug]; !ok {
+			slugs = append(slugs, slug)
+		}
+	}
+
+	slices.Sort(slugs)
+
+	return slugs
+}
@@ -0,0 +1,232 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"eatingisactivism/app/cdn"
+	"eatingisactivism/app/config"
+	"eatingisactivism/app/contentful"
+)
+
+// the green kitchen after an editor changed its slug
+const renamedLocation = `{
+	"total": 1,
+	"skip": 0,
+	"limit": 100,
+	"items": [{
+		"sys": {"id": "green-kitchen", "type": "Entry", "contentType": {"sys": {"id": "location"}}},
+		"fields": {"name": "The Green Kitchen", "slug": "the-green-kitchen"}
+	}]
+}`
+
+const publishWebhook = `{"sys":{"id":"green-kitchen","type":"Entry","contentType":{"sys":{"id":"location"}}}}`
+
+// newWebhookService returns a service loading from a fake Delivery API that
+// answers every request with body, purging through purger
+func newWebhookService(t *testing.T, body string, purger cdn.Purger) *Service {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	svc, err := New(config.Locations{
+		Source: config.SourceContentful,
+		Contentful: config.Contentful{
+			APIKey: "token",
+			SpaceID: "space",
+			BaseURL: server.URL,
+			Locale: "en-US",
+		},
+	}, purger)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc.store.PutLocation(Location{ID: "green-kitchen", Slug: "green-kitchen"})
+
+	return svc
+}
+
+func TestHandleWebhookPurgesOldAndNewSlug(t *testing.T) {
+	purger := &cdn.Fake{}
+	svc := newWebhookService(t, renamedLocation, purger)
+
+	err := svc.HandleWebhook(context.Background(), contentful.WebhookPublish, []byte(publishWebhook))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := svc.GetLocations()["the-green-kitchen"]; !ok {
+		t.Fatal("location isn't under its new slug")
+	}
+
+	wantURLs := []string{"/", "/locations", "/api/v1/locations", "/locations/green-kitchen", "/locations/the-green-kitchen"}
+
+	for _, url := range wantURLs {
+		if !slices.Contains(purger.URLs(), url) {
+			t.Errorf("didn't purge %s, purged %v", url, purger.URLs())
+		}
+	}
+
+	wantTags := []string{cdn.TagLocations, cdn.LocationTag("green-kitchen"), cdn.LocationTag("the-green-kitchen")}
+
+	for _, tag := range wantTags {
+		if !slices.Contains(purger.Tags(), tag) {
+			t.Errorf("didn't purge tag %s, purged %v", tag, purger.Tags())
+		}
+	}
+}
+
+func TestHandleWebhookPurgeFailureIsNotRetried(t *testing.T) {
+	purger := &cdn.Fake{Err: errors.New("cloudflare is down")}
+	svc := newWebhookService(t, renamedLocation, purger)
+
+	err := svc.HandleWebhook(context.Background(), contentful.WebhookPublish, []byte(publishWebhook))
+
+	if err != nil {
+		t.Fatalf("got %v, the change is applied so the webhook shouldn't fail", err)
+	}
+
+	if _, ok := svc.GetLocations()["the-green-kitchen"]; !ok {
+		t.Fatal("location isn't under its new slug")
+	}
+}
+
+func TestHandleWebhookUnpublishPurges(t *testing.T) {
+	purger := &cdn.Fake{}
+	svc := newWebhookService(t, renamedLocation, purger)
+
+	err := svc.HandleWebhook(context.Background(), contentful.WebhookUnpublish, []byte(publishWebhook))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(svc.GetLocations()) != 0 {
+		t.Fatalf("location wasn't removed: %v", svc.GetLocations())
+	}
+
+	if !slices.Contains(purger.URLs(), "/locations/green-kitchen") || !slices.Contains(purger.Tags(), cdn.LocationTag("green-kitchen")) {
+		t.Errorf("didn't purge the removed location, purged %v and tags %v", purger.URLs(), purger.Tags())
+	}
+}
+
+// funcSource refreshes by running refresh against the store
+type funcSource struct {
+	store *Store
+	refresh func(s *Store) error
+}
+
+func (f funcSource) Refresh(ctx context.Context) error {
+	return f.refresh(f.store)
+}
+
+func (f funcSource) Restore() bool {
+	return false
+}
+
+func TestRefreshPurgesChangedLocations(t *testing.T) {
+	purger := &cdn.Fake{}
+	store := NewStore()
+	store.PutLocation(Location{ID: "green-kitchen", Slug: "green-kitchen", Name: "Green Kitchen"})
+	store.PutLocation(Location{ID: "bean-counter", Slug: "bean-counter", Name: "Bean Counter"})
+	store.PutLocation(Location{ID: "closed-cafe", Slug: "closed-cafe", Name: "Closed Cafe"})
+
+	// a refresh picks up a rename, a removal and a new location that their
+	// webhooks never delivered
+	svc := &Service{store: store, purger: purger, source: funcSource{store: store, refresh: func(s *Store) error {
+		s.PutLocation(Location{ID: "green-kitchen", Slug: "the-green-kitchen", Name: "The Green Kitchen"})
+		s.DeleteLocation("closed-cafe")
+		s.PutLocation(Location{ID: "new-place", Slug: "new-place", Name: "New Place"})
+
+		return errors.New("tags failed to load")
+	}}}
+
+	err := svc.refresh(context.Background())
+
+	if err == nil {
+		t.Fatal("lost the refresh error")
+	}
+
+	for _, slug := range []string{"green-kitchen", "the-green-kitchen", "closed-cafe", "new-place"} {
+		if !slices.Contains(purger.URLs(), "/locations/" + slug) || !slices.Contains(purger.Tags(), cdn.LocationTag(slug)) {
+			t.Errorf("didn't purge %s, purged %v and tags %v", slug, purger.URLs(), purger.Tags())
+		}
+	}
+
+	if slices.Contains(purger.URLs(), "/locations/bean-counter") {
+		t.Error("purged a location that didn't change")
+	}
+
+	if !slices.Contains(purger.Tags(), cdn.TagLocations) || !slices.Contains(purger.URLs(), "/locations") {
+		t.Errorf("didn't purge the list pages, purged %v and tags %v", purger.URLs(), purger.Tags())
+	}
+}
+
+func TestRefreshWithoutChangesDoesNotPurge(t *testing.T) {
+	tests := []struct {
+		name string
+		store func() *Store
+		refresh func(s *Store) error
+	}{
+		{
+			name: "nothing changed",
+			store: func() *Store {
+				s := NewStore()
+				s.PutLocation(Location{ID: "green-kitchen", Slug: "green-kitchen"})
+				return s
+			},
+			refresh: func(s *Store) error {
+				return nil
+			},
+		},
+		{
+			name: "rebuilt the same",
+			store: func() *Store {
+				s := NewStore()
+				s.PutLocation(Location{ID: "green-kitchen", Slug: "green-kitchen"})
+				return s
+			},
+			refresh: func(s *Store) error {
+				s.Swap(s.Snapshot())
+				return nil
+			},
+		},
+		{
+			name: "first load",
+			store: NewStore,
+			refresh: func(s *Store) error {
+				s.PutLocation(Location{ID: "green-kitchen", Slug: "green-kitchen"})
+				return nil
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			purger := &cdn.Fake{}
+			store := test.store()
+			svc := &Service{store: store, purger: purger, source: funcSource{store: store, refresh: test.refresh}}
+
+			err := svc.refresh(context.Background())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(purger.URLs()) != 0 || len(purger.Tags()) != 0 {
+				t.Errorf("purged %v and tags %v", purger.URLs(), purger.Tags())
+			}
+		})
+	}
+}
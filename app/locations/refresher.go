@@ -0,0 +1,208 @@
+package locations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"eatingisactivism/app/contentful"
+)
+
+const minRefreshBackoff = 15 * time.Second
+
+type RefreshFunc func(ctx context.Context) error
+
+// RefreshStatus reports how the last refreshes went
+type RefreshStatus struct {
+	LastSuccess time.Time `json:"lastSuccess"`
+	LastError time.Time `json:"lastError"`
+	Error string `json:"error,omitempty"`
+	Failures int `json:"failures"`
+	Running bool `json:"running"`
+}
+
+// Refresher calls a RefreshFunc on an interval in the background. Each wait
+// gets a random jitter added so several machines don't all hit Contentful at
+// once, and consecutive failures back off exponentially up to the interval.
+type Refresher struct {
+	refresh RefreshFunc
+	interval time.Duration
+	jitter time.Duration
+
+	mu sync.Mutex
+	status RefreshStatus
+	retryAfter time.Duration
+	cancel context.CancelFunc
+	done chan struct{}
+}
+
+func NewRefresher(refresh RefreshFunc, interval time.Duration, jitter time.Duration) *Refresher {
+	return &Refresher{
+		refresh: refresh,
+		interval: interval,
+		jitter: jitter,
+	}
+}
+
+// Start runs the refresh loop until Stop is called. Calling Start on a running
+// refresher does nothing.
+func (r *Refresher) Start() {
+	r.start(false)
+}
+
+// StartNow is Start, except the first refresh runs straight away rather than
+// after the interval
+func (r *Refresher) StartNow() {
+	r.start(true)
+}
+
+func (r *Refresher) start(immediate bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cancel != nil || r.interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+	r.status.Running = true
+
+	go r.loop(ctx, r.done, immediate)
+}
+
+// Stop cancels the loop, including a refresh in flight, and waits for it to
+// exit or for ctx to be done.
+func (r *Refresher) Stop(ctx context.Context) error {
+	r.mu.Lock()
+	cancel := r.cancel
+	done := r.done
+	r.cancel = nil
+	r.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Refresh runs the RefreshFunc once and records the outcome. A panic in the
+// RefreshFunc is recovered and recorded as an error so it can't take the
+// loop down with it.
+func (r *Refresher) Refresh(ctx context.Context) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("refresh panicked: %v", p)
+		}
+
+		r.record(err)
+	}()
+
+	return r.refresh(ctx)
+}
+
+func (r *Refresher) Status() RefreshStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.status
+}
+
+func (r *Refresher) record(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.retryAfter = 0
+
+	if err != nil {
+		r.status.LastError = time.Now()
+		r.status.Error = err.Error()
+		r.status.Failures++
+
+		var rateLimit *contentful.RateLimitError
+
+		if errors.As(err, &rateLimit) {
+			r.retryAfter = rateLimit.RetryAfter
+		}
+
+		return
+	}
+
+	r.status.LastSuccess = time.Now()
+	r.status.Error = ""
+	r.status.Failures = 0
+}
+
+func (r *Refresher) loop(ctx context.Context, done chan struct{}, immediate bool) {
+	defer close(done)
+
+	defer func() {
+		r.mu.Lock()
+		r.status.Running = false
+		r.mu.Unlock()
+	}()
+
+	for {
+		wait := r.wait()
+
+		if immediate {
+			wait = 0
+			immediate = false
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := r.Refresh(ctx)
+
+		if err != nil && ctx.Err() == nil {
+			slog.Error("refresh from Contentful failed", "err", err, "failures", r.Status().Failures)
+		}
+	}
+}
+
+// wait returns how long to sleep before the next refresh
+func (r *Refresher) wait() time.Duration {
+	r.mu.Lock()
+	failures := r.status.Failures
+	retryAfter := r.retryAfter
+	r.mu.Unlock()
+
+	wait := r.interval
+
+	if failures > 0 {
+		wait = minRefreshBackoff
+
+		for i := 1; i < failures && wait < r.interval; i++ {
+			wait *= 2
+		}
+
+		// when Contentful said how long to back off for, wait at least that
+		wait = max(min(wait, r.interval), retryAfter)
+	}
+
+	if r.jitter > 0 {
+		wait += rand.N(r.jitter)
+	}
+
+	return wait
+}
@@ -0,0 +1,99 @@
+package locations
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+
+	"eatingisactivism/app/contentful"
+)
+
+// entryResolver finds the content type of entries we already hold in memory
+type entryResolver struct {
+	svc *Service
+}
+
+func (r entryResolver) ResolveEntry(id string) (string, bool) {
+	if r.svc.GetLocationByID(id).ID != "" {
+		return "location", true
+	}
+
+	if r.svc.GetStandardByID(id).ID != "" {
+		return "standard", true
+	}
+
+	if r.svc.GetTagByID(id).ID != "" {
+		return "tags", true
+	}
+
+	return "", false
+}
+
+func newRichTextRenderer(svc *Service) *contentful.RichTextRenderer {
+	renderer := contentful.NewRichTextRenderer(entryResolver{svc: svc})
+
+	renderer.RegisterEntry("location", svc.renderLocationEntry)
+	renderer.RegisterEntry("standard", svc.renderStandardEntry)
+	renderer.RegisterEntry("tags", svc.renderTagEntry)
+	renderer.RegisterAsset(svc.renderAsset)
+
+	return renderer
+}
+
+// RichText renders a rich text field, linking embedded locations to their
+// pages and showing embedded images. It is exposed to templates as "richText".
+func (svc *Service) RichText(data json.RawMessage) template.HTML {
+	return svc.richText.Render(data)
+}
+
+func (svc *Service) renderLocationEntry(node contentful.Node, id string, content string) template.HTML {
+	location := svc.GetLocationByID(id)
+	href := "/locations/" + html.EscapeString(location.Slug)
+
+	if content == "" {
+		content = html.EscapeString(location.Name)
+	}
+
+	if node.NodeType == "embedded-entry-block" {
+		return template.HTML(fmt.Sprintf(`<p class="embedded-location"><a href="%s">%s</a></p>`, href, content))
+	}
+
+	return template.HTML(fmt.Sprintf(`<a href="%s">%s</a>`, href, content))
+}
+
+func (svc *Service) renderStandardEntry(node contentful.Node, id string, content string) template.HTML {
+	if content == "" {
+		content = html.EscapeString(svc.GetStandardByID(id).Name)
+	}
+
+	return template.HTML(fmt.Sprintf(`<span class="embedded-standard">%s</span>`, content))
+}
+
+func (svc *Service) renderTagEntry(node contentful.Node, id string, content string) template.HTML {
+	if content == "" {
+		content = html.EscapeString(svc.GetTagByID(id).Name)
+	}
+
+	return template.HTML(fmt.Sprintf(`<span class="embedded-tag">%s</span>`, content))
+}
+
+func (svc *Service) renderAsset(node contentful.Node, id string) template.HTML {
+	asset, ok := svc.store.Assets()[id]
+
+	if !ok || asset.URL == "" {
+		return ""
+	}
+
+	if asset.IsImage() {
+		return template.HTML(fmt.Sprintf(`<figure class="embedded-asset"><img src="%s" srcset="%s" sizes="(min-width: 640px) 576px, 100vw" alt="%s" loading="lazy"></figure>`, html.EscapeString(asset.Image(1200, 0)), html.EscapeString(asset.Srcset(600, 1200)), html.EscapeString(asset.Description)))
+	}
+
+	title := asset.Title
+
+	if title == "" {
+		title = asset.URL
+	}
+
+	return template.HTML(fmt.Sprintf(`<p class="embedded-asset"><a href="%s" target="_blank" rel="noopener">%s</a></p>`, html.EscapeString(asset.URL), html.EscapeString(title)))
+}
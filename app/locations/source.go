@@ -0,0 +1,64 @@
+package locations
+
+import (
+	"context"
+
+	"eatingisactivism/app/config"
+	"eatingisactivism/app/contentful"
+)
+
+// Source is where locations, standards and tags come from
+type Source interface {
+	// Refresh brings the store up to date
+	Refresh(ctx context.Context) error
+	// Restore fills the store from a local copy without waiting on the
+	// source, reporting whether there was one
+	Restore() bool
+}
+
+// ContentfulSource loads from Contentful through the Sync API, keeping a
+// snapshot on disk to boot from
+type ContentfulSource struct {
+	store *Store
+	// client reads drafts from the Preview API in preview mode, where
+	// delivery reads published content to tell drafts apart. delivery is nil
+	// otherwise.
+	client *contentful.Contentful
+	delivery *contentful.Contentful
+	// locales are the locales content is loaded in, the default first
+	locales []string
+	// snapshotPath is where the last good snapshot is kept, empty turns it off
+	snapshotPath string
+}
+
+// NewContentfulSource builds the Contentful clients for cfg, loading into
+// store in locales
+func NewContentfulSource(cfg config.Contentful, store *Store, locales []string) *ContentfulSource {
+	options := []contentful.Option{
+		contentful.WithTimeout(cfg.Timeout),
+		contentful.WithEnvironment(cfg.Environment),
+		contentful.WithLocale(locales[0]),
+	}
+
+	cs := &ContentfulSource{
+		store: store,
+		client: contentful.New(cfg.APIKey, cfg.SpaceID, cfg.BaseURL, options...),
+		locales: locales,
+		snapshotPath: cfg.SnapshotPath,
+	}
+
+	if cfg.Preview {
+		cs.delivery = cs.client
+		cs.client = previewClient(cfg, options)
+	}
+
+	return cs
+}
+
+func (cs *ContentfulSource) Refresh(ctx context.Context) error {
+	return cs.refreshData(ctx)
+}
+
+func (cs *ContentfulSource) Restore() bool {
+	return cs.restoreSnapshot()
+}
@@ -0,0 +1,425 @@
+package locations
+
+import (
+	"maps"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is a point in time view of all locations, standards, tags and
+// assets. A snapshot handed out by a Store is never modified, so it can be read
+// without holding a lock. Callers must not modify the maps of a snapshot they
+// didn't create themselves.
+type Snapshot struct {
+	Locations LocationMap
+	Standards LocationStandardMap
+	Tags LocationTagMap
+	Assets AssetMap
+	// Links records the standard and tags each location links to by location
+	// ID, including links that didn't resolve when the location was built
+	Links LocationLinksMap
+	// SyncToken is the Sync API token the data is current as of, empty when
+	// the data didn't come from a sync
+	SyncToken string
+	// Version goes up with every change the store makes, UpdatedAt is when
+	// the last one was made
+	Version uint64
+	UpdatedAt time.Time
+}
+
+// Store holds the current snapshot. Reads load the snapshot atomically, writes
+// copy the map they touch and swap in a new snapshot, so readers never see a
+// map that is being written to.
+type Store struct {
+	mu sync.Mutex
+	current atomic.Pointer[Snapshot]
+}
+
+func NewSnapshot() Snapshot {
+	return Snapshot{
+		Locations: LocationMap{},
+		Standards: LocationStandardMap{},
+		Tags: LocationTagMap{},
+		Assets: AssetMap{},
+		Links: LocationLinksMap{},
+	}
+}
+
+func NewStore() *Store {
+	s := &Store{}
+	snapshot := NewSnapshot()
+	s.current.Store(&snapshot)
+
+	return s
+}
+
+// Snapshot returns the current data
+func (s *Store) Snapshot() Snapshot {
+	return *s.current.Load()
+}
+
+// Swap replaces all data at once. The maps in next belong to the store
+// afterwards and must not be modified.
+func (s *Store) Swap(next Snapshot) {
+	s.swap(next, func(current *Snapshot) bool {
+		return true
+	})
+}
+
+// CompareAndSwap replaces all data like Swap, but only while the store is
+// still at version, reporting whether it was. A rebuild passes the version it
+// started from, so it can't overwrite a change made while it was fetching.
+func (s *Store) CompareAndSwap(version uint64, next Snapshot) bool {
+	return s.swap(next, func(current *Snapshot) bool {
+		return current.Version == version
+	})
+}
+
+func (s *Store) swap(next Snapshot, ok func(current *Snapshot) bool) bool {
+	if next.Locations == nil {
+		next.Locations = LocationMap{}
+	}
+
+	if next.Standards == nil {
+		next.Standards = LocationStandardMap{}
+	}
+
+	if next.Tags == nil {
+		next.Tags = LocationTagMap{}
+	}
+
+	if next.Assets == nil {
+		next.Assets = AssetMap{}
+	}
+
+	if next.Links == nil {
+		next.Links = LocationLinksMap{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.current.Load()
+
+	if !ok(current) {
+		return false
+	}
+
+	next.Version = current.Version + 1
+	next.UpdatedAt = time.Now()
+	s.current.Store(&next)
+
+	return true
+}
+
+// update runs fn against a shallow copy of the current snapshot and stores the
+// result. fn must clone any map it changes and report whether it changed
+// anything. Nothing is stored when it didn't, so the version only goes up on a
+// real change.
+func (s *Store) update(fn func(next *Snapshot) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := *s.current.Load()
+
+	if !fn(&next) {
+		return
+	}
+
+	next.Version++
+	next.UpdatedAt = time.Now()
+	s.current.Store(&next)
+}
+
+func (s *Store) Locations() LocationMap {
+	return s.current.Load().Locations
+}
+
+func (s *Store) Standards() LocationStandardMap {
+	return s.current.Load().Standards
+}
+
+func (s *Store) Tags() LocationTagMap {
+	return s.current.Load().Tags
+}
+
+func (s *Store) Assets() AssetMap {
+	return s.current.Load().Assets
+}
+
+// SetSyncToken records the Sync API token. The data itself doesn't change, so
+// neither does the version.
+func (s *Store) SetSyncToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := *s.current.Load()
+	next.SyncToken = token
+	s.current.Store(&next)
+}
+
+// SetDraft flags the location with ID id as a draft, or as published
+func (s *Store) SetDraft(id string, draft bool) {
+	s.update(func(next *Snapshot) bool {
+		location, ok := next.LocationByID(id)
+
+		if !ok || location.Draft == draft {
+			return false
+		}
+
+		next.Locations = maps.Clone(next.Locations)
+		location.Draft = draft
+		next.Locations[location.Slug] = location
+
+		return true
+	})
+}
+
+// PutLocation adds or replaces a location. A location whose slug changed is
+// removed from under its old slug. Putting what is already there changes
+// nothing, the same goes for standards, tags and assets.
+func (s *Store) PutLocation(location Location) {
+	if location.ID == "" || location.Slug == "" {
+		return
+	}
+
+	s.update(func(next *Snapshot) bool {
+		old, ok := next.LocationByID(location.ID)
+
+		if ok && reflect.DeepEqual(old, location) {
+			return false
+		}
+
+		next.Locations = maps.Clone(next.Locations)
+
+		if ok {
+			delete(next.Locations, old.Slug)
+		}
+
+		next.Locations[location.Slug] = location
+
+		next.Links = maps.Clone(next.Links)
+		next.Links[location.ID] = location.linkIDs()
+
+		return true
+	})
+}
+
+func (s *Store) PutStandard(standard LocationStandard) {
+	if standard.ID == "" || standard.Slug == "" {
+		return
+	}
+
+	s.update(func(next *Snapshot) bool {
+		old, ok := next.StandardByID(standard.ID)
+
+		if ok && reflect.DeepEqual(old, standard) {
+			return false
+		}
+
+		next.Standards = maps.Clone(next.Standards)
+
+		if ok {
+			delete(next.Standards, old.Slug)
+		}
+
+		next.Standards[standard.Slug] = standard
+
+		next.relink(func(links LocationLinks) bool {
+			return links.StandardID == standard.ID
+		})
+
+		return true
+	})
+}
+
+func (s *Store) PutTag(tag LocationTag) {
+	if tag.ID == "" || tag.Slug == "" {
+		return
+	}
+
+	s.update(func(next *Snapshot) bool {
+		old, ok := next.TagByID(tag.ID)
+
+		if ok && reflect.DeepEqual(old, tag) {
+			return false
+		}
+
+		next.Tags = maps.Clone(next.Tags)
+
+		if ok {
+			delete(next.Tags, old.Slug)
+		}
+
+		next.Tags[tag.Slug] = tag
+
+		next.relink(func(links LocationLinks) bool {
+			return links.hasTag(tag.ID)
+		})
+
+		return true
+	})
+}
+
+func (s *Store) PutAsset(asset Asset) {
+	if asset.ID == "" {
+		return
+	}
+
+	s.update(func(next *Snapshot) bool {
+		if old, ok := next.Assets[asset.ID]; ok && reflect.DeepEqual(old, asset) {
+			return false
+		}
+
+		next.Assets = maps.Clone(next.Assets)
+		next.Assets[asset.ID] = asset
+
+		next.relink(func(links LocationLinks) bool {
+			return links.hasAsset(asset.ID)
+		})
+
+		return true
+	})
+}
+
+func (s *Store) DeleteLocation(id string) {
+	s.update(func(next *Snapshot) bool {
+		old, ok := next.LocationByID(id)
+
+		if !ok {
+			return false
+		}
+
+		next.Locations = maps.Clone(next.Locations)
+		delete(next.Locations, old.Slug)
+
+		next.Links = maps.Clone(next.Links)
+		delete(next.Links, id)
+
+		return true
+	})
+}
+
+func (s *Store) DeleteStandard(id string) {
+	s.update(func(next *Snapshot) bool {
+		old, ok := next.StandardByID(id)
+
+		if !ok {
+			return false
+		}
+
+		next.Standards = maps.Clone(next.Standards)
+		delete(next.Standards, old.Slug)
+
+		next.relink(func(links LocationLinks) bool {
+			return links.StandardID == id
+		})
+
+		return true
+	})
+}
+
+func (s *Store) DeleteTag(id string) {
+	s.update(func(next *Snapshot) bool {
+		old, ok := next.TagByID(id)
+
+		if !ok {
+			return false
+		}
+
+		next.Tags = maps.Clone(next.Tags)
+		delete(next.Tags, old.Slug)
+
+		next.relink(func(links LocationLinks) bool {
+			return links.hasTag(id)
+		})
+
+		return true
+	})
+}
+
+func (s *Store) DeleteAsset(id string) {
+	s.update(func(next *Snapshot) bool {
+		if _, ok := next.Assets[id]; !ok {
+			return false
+		}
+
+		next.Assets = maps.Clone(next.Assets)
+		delete(next.Assets, id)
+
+		next.relink(func(links LocationLinks) bool {
+			return links.hasAsset(id)
+		})
+
+		return true
+	})
+}
+
+func (snapshot Snapshot) LocationByID(id string) (Location, bool) {
+	for _, location := range snapshot.Locations {
+		if location.ID == id {
+			return location, true
+		}
+	}
+
+	return Location{}, false
+}
+
+func (snapshot Snapshot) StandardByID(id string) (LocationStandard, bool) {
+	for _, standard := range snapshot.Standards {
+		if standard.ID == id {
+			return standard, true
+		}
+	}
+
+	return LocationStandard{}, false
+}
+
+func (snapshot Snapshot) TagByID(id string) (LocationTag, bool) {
+	for _, tag := range snapshot.Tags {
+		if tag.ID == id {
+			return tag, true
+		}
+	}
+
+	return LocationTag{}, false
+}
+
+// relink rebuilds the standard, tags and images of every location whose links
+// match from the standards, tags and assets in the snapshot. Links that no
+// longer resolve leave the standard or hero image empty, or the tag or image
+// out.
+func (snapshot *Snapshot) relink(affected func(links LocationLinks) bool) {
+	cloned := false
+
+	for slug, location := range snapshot.Locations {
+		links, ok := snapshot.Links[location.ID]
+
+		if !ok || !affected(links) {
+			continue
+		}
+
+		if !cloned {
+			snapshot.Locations = maps.Clone(snapshot.Locations)
+			cloned = true
+		}
+
+		location.Standard, _ = snapshot.StandardByID(links.StandardID)
+		location.Tags = []LocationTag{}
+
+		for _, id := range links.TagIDs {
+			tag, ok := snapshot.TagByID(id)
+
+			if ok {
+				location.Tags = append(location.Tags, tag)
+			}
+		}
+
+		location.HeroImage = snapshot.heroImage(links.HeroImageID)
+		location.Gallery = snapshot.gallery(links.GalleryIDs)
+
+		snapshot.Locations[slug] = location
+	}
+}
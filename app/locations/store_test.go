@@ -0,0 +1,305 @@
+package locations
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// TestStoreConcurrentAccess runs webhook style writes and rebuild style swaps
+// against readers. Run it with -race, a map written while it is being read
+// fails it.
+func TestStoreConcurrentAccess(t *testing.T) {
+	s := NewStore()
+	done := make(chan struct{})
+
+	var writers sync.WaitGroup
+	var readers sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+
+		go func(w int) {
+			defer writers.Done()
+
+			for i := 0; i < 200; i++ {
+				tag := LocationTag{ID: fmt.Sprintf("tag-%d-%d", w, i), Slug: fmt.Sprintf("tag-%d-%d", w, i)}
+				id := fmt.Sprintf("location-%d-%d", w, i)
+
+				s.PutTag(tag)
+				s.PutLocation(Location{ID: id, Slug: id, Tags: []LocationTag{tag}})
+				s.DeleteTag(tag.ID)
+
+				if i % 10 == 0 {
+					s.DeleteLocation(id)
+				}
+			}
+		}(w)
+	}
+
+	writers.Add(1)
+
+	go func() {
+		defer writers.Done()
+
+		// a rebuild only swaps in over the version it started from, so it
+		// can't undo a write made in between
+		for i := 0; i < 50; i++ {
+			for {
+				base := s.Snapshot()
+				next := base
+				next.Locations = LocationMap{}
+				next.Links = LocationLinksMap{}
+
+				if s.CompareAndSwap(base.Version, next) {
+					break
+				}
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+
+		go func() {
+			defer readers.Done()
+
+			var lastVersion uint64
+
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				for slug, location := range s.Locations() {
+					if slug != location.Slug {
+						t.Errorf("location %s is stored under slug %s", location.ID, slug)
+					}
+				}
+
+				snapshot := s.Snapshot()
+
+				if snapshot.Version < lastVersion {
+					t.Errorf("version went back from %d to %d", lastVersion, snapshot.Version)
+				}
+
+				lastVersion = snapshot.Version
+
+				for _, location := range snapshot.Locations {
+					if _, ok := snapshot.Links[location.ID]; !ok {
+						t.Errorf("location %s has no links in its snapshot", location.ID)
+					}
+				}
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	// every tag was deleted after the location using it was put, so relinking
+	// must have taken them all off again
+	for _, location := range s.Snapshot().Locations {
+		if len(location.Tags) != 0 {
+			t.Errorf("location %s kept tags %v after they were deleted", location.ID, location.Tags)
+		}
+	}
+}
+
+func TestStoreCompareAndSwap(t *testing.T) {
+	s := NewStore()
+	base := s.Snapshot()
+
+	// a webhook lands while the rebuild is fetching
+	s.PutLocation(Location{ID: "webhook", Slug: "webhook"})
+
+	next := NewSnapshot()
+	next.Locations["rebuilt"] = Location{ID: "rebuilt", Slug: "rebuilt"}
+
+	if s.CompareAndSwap(base.Version, next) {
+		t.Fatal("swapped over a change made after the rebuild started")
+	}
+
+	if _, ok := s.Locations()["webhook"]; !ok {
+		t.Fatal("lost the webhook's location")
+	}
+
+	if !s.CompareAndSwap(s.Snapshot().Version, next) {
+		t.Fatal("didn't swap at the current version")
+	}
+
+	if _, ok := s.Locations()["rebuilt"]; !ok {
+		t.Fatal("rebuilt location missing after the swap")
+	}
+}
+
+// linkedLocation links to the vegan standard, the brunch tag and two images
+func linkedLocation() Location {
+	return Location{
+		ID: "green-kitchen",
+		Slug: "green-kitchen",
+		links: &LocationLinks{
+			StandardID: "vegan",
+			TagIDs: []string{"brunch", "late"},
+			HeroImageID: "front",
+			GalleryIDs: []string{"front", "inside"},
+		},
+	}
+}
+
+func TestStoreRenamesReachLocations(t *testing.T) {
+	s := NewStore()
+	s.PutStandard(LocationStandard{ID: "vegan", Slug: "vegan", Name: "Vegan"})
+	s.PutTag(LocationTag{ID: "brunch", Slug: "brunch", Name: "Brunch"})
+	s.PutTag(LocationTag{ID: "late", Slug: "late", Name: "Open late"})
+	s.PutAsset(Asset{ID: "front", URL: "https://images.ctfassets.net/front.jpg"})
+	s.PutAsset(Asset{ID: "inside", URL: "https://images.ctfassets.net/inside.jpg"})
+	s.PutLocation(linkedLocation())
+	s.PutLocation(Location{ID: "bean-counter", Slug: "bean-counter"})
+
+	unrelated := s.Locations()["bean-counter"]
+
+	s.PutStandard(LocationStandard{ID: "vegan", Slug: "plant-based", Name: "Plant based"})
+	s.PutTag(LocationTag{ID: "brunch", Slug: "all-day-brunch", Name: "All day brunch"})
+	s.PutAsset(Asset{ID: "front", URL: "https://images.ctfassets.net/front-v2.jpg"})
+
+	location := s.Locations()["green-kitchen"]
+
+	if location.Standard.Slug != "plant-based" || location.Standard.Name != "Plant based" {
+		t.Errorf("got standard %+v, want the renamed one", location.Standard)
+	}
+
+	if _, ok := s.Standards()["vegan"]; ok {
+		t.Error("standard is still under its old slug")
+	}
+
+	if len(location.Tags) != 2 || location.Tags[0].Slug != "all-day-brunch" || location.Tags[1].Slug != "late" {
+		t.Errorf("got tags %+v, want the renamed one first", location.Tags)
+	}
+
+	if _, ok := s.Tags()["brunch"]; ok {
+		t.Error("tag is still under its old slug")
+	}
+
+	if location.HeroImage == nil || location.HeroImage.URL != "https://images.ctfassets.net/front-v2.jpg" {
+		t.Errorf("got hero image %+v, want the republished one", location.HeroImage)
+	}
+
+	if len(location.Gallery) != 2 || location.Gallery[0].URL != "https://images.ctfassets.net/front-v2.jpg" {
+		t.Errorf("got gallery %+v, want the republished image in it", location.Gallery)
+	}
+
+	if !reflect.DeepEqual(s.Locations()["bean-counter"], unrelated) {
+		t.Error("changed a location that doesn't link to anything renamed")
+	}
+}
+
+func TestStoreLinksResolveLater(t *testing.T) {
+	s := NewStore()
+
+	// the location arrives before what it links to, as it can from a webhook
+	s.PutLocation(linkedLocation())
+
+	location := s.Locations()["green-kitchen"]
+
+	if location.Standard.ID != "" || len(location.Tags) != 0 || location.HeroImage != nil || len(location.Gallery) != 0 {
+		t.Fatalf("got links resolved to nothing: %+v", location)
+	}
+
+	s.PutAsset(Asset{ID: "inside", URL: "https://images.ctfassets.net/inside.jpg"})
+	s.PutTag(LocationTag{ID: "late", Slug: "late"})
+	s.PutStandard(LocationStandard{ID: "vegan", Slug: "vegan"})
+	s.PutTag(LocationTag{ID: "brunch", Slug: "brunch"})
+	s.PutAsset(Asset{ID: "front", URL: "https://images.ctfassets.net/front.jpg"})
+
+	location = s.Locations()["green-kitchen"]
+
+	if location.Standard.Slug != "vegan" {
+		t.Errorf("got standard %+v", location.Standard)
+	}
+
+	// tags and images keep the order the location lists them in, not the
+	// order they arrived in
+	tags := []string{}
+
+	for _, tag := range location.Tags {
+		tags = append(tags, tag.Slug)
+	}
+
+	if fmt.Sprint(tags) != "[brunch late]" {
+		t.Errorf("got tags %v", tags)
+	}
+
+	if location.HeroImage == nil || location.HeroImage.ID != "front" {
+		t.Errorf("got hero image %+v", location.HeroImage)
+	}
+
+	if len(location.Gallery) != 2 || location.Gallery[0].ID != "front" || location.Gallery[1].ID != "inside" {
+		t.Errorf("got gallery %+v", location.Gallery)
+	}
+
+	// a link that goes away leaves the location without it again
+	s.DeleteStandard("vegan")
+	s.DeleteAsset("front")
+
+	location = s.Locations()["green-kitchen"]
+
+	if location.Standard.ID != "" || location.HeroImage != nil || len(location.Gallery) != 1 {
+		t.Errorf("kept deleted links: %+v", location)
+	}
+}
+
+// a rebuild starts over and the JSON is rebuilt whenever the version moves, so
+// writes that change nothing leave it alone
+func TestStoreVersionOnlyMovesOnChange(t *testing.T) {
+	s := NewStore()
+	s.PutStandard(LocationStandard{ID: "vegan", Slug: "vegan", Name: "Vegan"})
+	s.PutTag(LocationTag{ID: "brunch", Slug: "brunch", Name: "Brunch"})
+	s.PutAsset(Asset{ID: "front", URL: "https://images.ctfassets.net/front.jpg"})
+	s.PutLocation(linkedLocation())
+
+	unchanged := []struct {
+		name string
+		write func()
+	}{
+		{name: "delete an unknown location", write: func() { s.DeleteLocation("missing") }},
+		{name: "delete an unknown standard", write: func() { s.DeleteStandard("missing") }},
+		{name: "delete an unknown tag", write: func() { s.DeleteTag("missing") }},
+		{name: "delete an unknown asset", write: func() { s.DeleteAsset("missing") }},
+		{name: "remove an unknown entry", write: func() { removeEntry(s, "", "missing") }},
+		{name: "draft flag already set", write: func() { s.SetDraft("green-kitchen", false) }},
+		{name: "draft flag on an unknown location", write: func() { s.SetDraft("missing", true) }},
+		{name: "same standard", write: func() { s.PutStandard(LocationStandard{ID: "vegan", Slug: "vegan", Name: "Vegan"}) }},
+		{name: "same tag", write: func() { s.PutTag(LocationTag{ID: "brunch", Slug: "brunch", Name: "Brunch"}) }},
+		{name: "same asset", write: func() { s.PutAsset(Asset{ID: "front", URL: "https://images.ctfassets.net/front.jpg"}) }},
+		{name: "same location", write: func() { s.PutLocation(s.Locations()["green-kitchen"]) }},
+	}
+
+	for _, test := range unchanged {
+		before := s.Snapshot().Version
+		test.write()
+
+		if after := s.Snapshot().Version; after != before {
+			t.Errorf("%s moved the version from %d to %d", test.name, before, after)
+		}
+	}
+
+	before := s.Snapshot().Version
+
+	// a sync deletion doesn't say what it deleted, but only one map holds it
+	removeEntry(s, "", "green-kitchen")
+
+	if after := s.Snapshot().Version; after != before + 1 {
+		t.Errorf("removing one entry moved the version from %d to %d, want one step", before, after)
+	}
+
+	s.PutTag(LocationTag{ID: "brunch", Slug: "brunch", Name: "All day brunch"})
+
+	if after := s.Snapshot().Version; after != before + 2 {
+		t.Errorf("renaming a tag moved the version from %d to %d, want one step", before + 1, after)
+	}
+}
@@ -0,0 +1,191 @@
+package locations
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"eatingisactivism/app/contentful"
+)
+
+// maxRebuildAttempts is how many times a rebuild starts over when the store
+// keeps changing under it
+const maxRebuildAttempts = 3
+
+// ErrStoreChanged is returned when a rebuild gave up because the store kept
+// changing while it was fetching. The store keeps its current data.
+var ErrStoreChanged = errors.New("locations: store changed during rebuild")
+
+// refreshData brings the store up to date and saves it to disk once it is
+func (cs *ContentfulSource) refreshData(ctx context.Context) error {
+	err := cs.loadData(ctx)
+
+	if err == nil {
+		cs.persistSnapshot()
+	}
+
+	return err
+}
+
+// loadData brings the store up to date through the Sync API, falling back to a
+// full rebuild from the entries endpoint when the sync fails
+func (cs *ContentfulSource) loadData(ctx context.Context) error {
+	// deltas from a Preview API sync aren't supported, and a preview needs
+	// the Delivery API to tell drafts apart anyway
+	if cs.Preview() {
+		return cs.buildData(ctx)
+	}
+
+	err := cs.syncData(ctx)
+
+	if err == nil {
+		return nil
+	}
+
+	// cancelled or out of time, a rebuild wouldn't get any further
+	if ctx.Err() != nil {
+		return err
+	}
+
+	slog.Warn("sync from Contentful failed, rebuilding", "err", err)
+
+	// a token that stopped working would fail every delta sync, so the next
+	// refresh starts over with an initial sync
+	cs.store.SetSyncToken("")
+
+	return errors.Join(err, cs.buildData(ctx))
+}
+
+// syncData runs the Sync API. Without a token it runs an initial sync into a
+// fresh store and swaps it in, otherwise it applies the changes since the last
+// sync to the live store.
+func (cs *ContentfulSource) syncData(ctx context.Context) error {
+	token := cs.store.Snapshot().SyncToken
+
+	if token == "" {
+		return cs.initialSync(ctx)
+	}
+
+	result, err := cs.client.Sync(ctx, token)
+
+	if err != nil {
+		return err
+	}
+
+	err = cs.applySyncItems(cs.store, result.Items)
+	cs.store.SetSyncToken(result.NextSyncToken)
+
+	return err
+}
+
+// initialSync runs an initial sync into a fresh store and swaps it in
+func (cs *ContentfulSource) initialSync(ctx context.Context) error {
+	return cs.swapRebuilt(ctx, func(base Snapshot) (*Snapshot, error) {
+		result, err := cs.client.Sync(ctx, "")
+
+		if err != nil {
+			return nil, err
+		}
+
+		fresh := NewStore()
+		err = cs.applySyncItems(fresh, result.Items)
+
+		next := fresh.Snapshot()
+		next.SyncToken = result.NextSyncToken
+
+		return &next, err
+	})
+}
+
+// swapRebuilt swaps in the snapshot build makes, as long as the store didn't
+// change while build was fetching. If it did, a webhook was applied meanwhile
+// and build starts over, so the rebuild picks up the change rather than
+// overwriting it. A nil snapshot from build leaves the store alone.
+func (cs *ContentfulSource) swapRebuilt(ctx context.Context, build func(base Snapshot) (*Snapshot, error)) error {
+	for attempt := 1; ; attempt++ {
+		base := cs.store.Snapshot()
+		next, err := build(base)
+
+		if next == nil || cs.store.CompareAndSwap(base.Version, *next) {
+			return err
+		}
+
+		if attempt == maxRebuildAttempts || ctx.Err() != nil {
+			return errors.Join(err, ErrStoreChanged)
+		}
+
+		slog.Info("store changed during a rebuild, starting over", "attempt", attempt)
+	}
+}
+
+// syncOrder puts assets, standards and tags ahead of the locations that link
+// to them, and deletions last
+func syncOrder(item contentful.SyncItem) int {
+	switch item.Sys.Type {
+		case contentful.SyncAsset:
+			return 0
+		case contentful.SyncEntry:
+			if item.ContentTypeID() == "location" {
+				return 2
+			}
+
+			return 1
+	}
+
+	return 3
+}
+
+// applySyncItems applies sync items to s through the same functions webhooks
+// use. An item that fails to decode is skipped and its error returned once
+// the rest have been applied.
+func (cs *ContentfulSource) applySyncItems(s *Store, items []contentful.SyncItem) error {
+	items = slices.Clone(items)
+
+	slices.SortStableFunc(items, func(a, b contentful.SyncItem) int {
+		return cmp.Compare(syncOrder(a), syncOrder(b))
+	})
+
+	var errs []error
+
+	for _, item := range items {
+		switch item.Sys.Type {
+			case contentful.SyncEntry:
+				data, err := item.Localize(cs.locales[0], cs.locales[0])
+
+				var translations Translations
+
+				if err == nil {
+					translations, err = cs.syncTranslations(item)
+				}
+
+				if err == nil {
+					err = applyEntry(s, item.ContentTypeID(), data, nil, translations)
+				}
+
+				if err != nil {
+					slog.Error("applying synced entry failed", "entryID", item.Sys.ID, "contentType", item.ContentTypeID(), "err", err)
+					errs = append(errs, fmt.Errorf("entry %s: %w", item.Sys.ID, err))
+				}
+			case contentful.SyncAsset:
+				data, err := item.Localize(cs.locales[0], cs.locales[0])
+
+				if err == nil {
+					err = applyAsset(s, data)
+				}
+
+				if err != nil {
+					slog.Error("applying synced asset failed", "assetID", item.Sys.ID, "err", err)
+					errs = append(errs, fmt.Errorf("asset %s: %w", item.Sys.ID, err))
+				}
+			case contentful.SyncDeletedEntry:
+				removeEntry(s, item.ContentTypeID(), item.Sys.ID)
+			case contentful.SyncDeletedAsset:
+				s.DeleteAsset(item.Sys.ID)
+		}
+	}
+
+	return errors.Join(errs...)
+}
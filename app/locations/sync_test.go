@@ -0,0 +1,261 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"eatingisactivism/app/config"
+	"eatingisactivism/app/contentful"
+)
+
+const (
+	syncedAsset = `{"sys":{"id":"photo","type":"Asset"},"fields":{"title":{"en-US":"Front"},"file":{"en-US":{"url":"//images.ctfassets.net/photo.jpg","contentType":"image/jpeg"}}}}`
+	syncedStandard = `{"sys":{"id":"vegan","type":"Entry","contentType":{"sys":{"id":"standard"}}},"fields":{"title":{"en-US":"Vegan"},"slug":{"en-US":"vegan"}}}`
+	syncedTag = `{"sys":{"id":"brunch","type":"Entry","contentType":{"sys":{"id":"tags"}}},"fields":{"title":{"en-US":"Brunch"},"slug":{"en-US":"brunch"}}}`
+	syncedLocation = `{"sys":{"id":"green-kitchen","type":"Entry","contentType":{"sys":{"id":"location"}}},"fields":{
+		"name":{"en-US":"Green Kitchen"},
+		"slug":{"en-US":"green-kitchen"},
+		"standard":{"en-US":{"sys":{"type":"Link","linkType":"Entry","id":"vegan"}}},
+		"tags":{"en-US":[{"sys":{"type":"Link","linkType":"Entry","id":"brunch"}}]},
+		"heroImage":{"en-US":{"sys":{"type":"Link","linkType":"Asset","id":"photo"}}}
+	}}`
+)
+
+func syncItems(t *testing.T, items ...string) []contentful.SyncItem {
+	t.Helper()
+
+	decoded := []contentful.SyncItem{}
+
+	for _, item := range items {
+		var syncItem contentful.SyncItem
+
+		err := json.Unmarshal([]byte(item), &syncItem)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded = append(decoded, syncItem)
+	}
+
+	return decoded
+}
+
+func TestSyncOrder(t *testing.T) {
+	items := syncItems(t,
+		`{"sys":{"id":"closed-cafe","type":"DeletedEntry"}}`,
+		syncedLocation,
+		syncedTag,
+		`{"sys":{"id":"old-photo","type":"DeletedAsset"}}`,
+		syncedStandard,
+		syncedAsset,
+	)
+
+	ranks := []int{}
+
+	for _, item := range items {
+		ranks = append(ranks, syncOrder(item))
+	}
+
+	// assets, then standards and tags, then locations, then deletions
+	want := []int{3, 2, 1, 3, 1, 0}
+
+	if !slices.Equal(ranks, want) {
+		t.Errorf("got ranks %v, want %v", ranks, want)
+	}
+}
+
+func TestApplySyncItems(t *testing.T) {
+	cs := &ContentfulSource{locales: []string{"en-US"}}
+	s := NewStore()
+
+	// the location comes first, as it may in a sync, and still links up
+	err := cs.applySyncItems(s, syncItems(t, syncedLocation, syncedTag, syncedStandard, syncedAsset))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	location, ok := s.Locations()["green-kitchen"]
+
+	if !ok {
+		t.Fatalf("location missing, got %v", s.Locations())
+	}
+
+	if location.Standard.Slug != "vegan" {
+		t.Errorf("got standard %+v", location.Standard)
+	}
+
+	if len(location.Tags) != 1 || location.Tags[0].Slug != "brunch" {
+		t.Errorf("got tags %+v", location.Tags)
+	}
+
+	if location.HeroImage == nil || location.HeroImage.URL != "https://images.ctfassets.net/photo.jpg" {
+		t.Errorf("got hero image %+v", location.HeroImage)
+	}
+}
+
+func TestApplySyncItemsDeletionWithoutContentType(t *testing.T) {
+	cs := &ContentfulSource{locales: []string{"en-US"}}
+	s := NewStore()
+
+	err := cs.applySyncItems(s, syncItems(t, syncedLocation, syncedTag, syncedStandard, syncedAsset))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// deletions from the Sync API don't say what they were
+	err = cs.applySyncItems(s, syncItems(t,
+		`{"sys":{"id":"vegan","type":"DeletedEntry"}}`,
+		`{"sys":{"id":"photo","type":"DeletedAsset"}}`,
+	))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Standards()) != 0 || len(s.Assets()) != 0 {
+		t.Fatalf("got standards %v and assets %v after deleting them", s.Standards(), s.Assets())
+	}
+
+	location := s.Locations()["green-kitchen"]
+
+	if location.Standard.ID != "" || location.HeroImage != nil {
+		t.Errorf("location kept its deleted standard %+v or image %+v", location.Standard, location.HeroImage)
+	}
+
+	err = cs.applySyncItems(s, syncItems(t, `{"sys":{"id":"green-kitchen","type":"DeletedEntry"}}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Locations()) != 0 {
+		t.Errorf("got locations %v after deleting it", s.Locations())
+	}
+}
+
+func TestApplySyncItemsSkipsBadItems(t *testing.T) {
+	cs := &ContentfulSource{locales: []string{"en-US"}}
+	s := NewStore()
+
+	err := cs.applySyncItems(s, syncItems(t,
+		`{"sys":{"id":"broken","type":"Entry","contentType":{"sys":{"id":"location"}}},"fields":{"name":{"en-US":42}}}`,
+		syncedStandard,
+	))
+
+	if err == nil {
+		t.Error("got no error for an entry that doesn't decode")
+	}
+
+	if _, ok := s.Standards()["vegan"]; !ok {
+		t.Error("a bad item stopped the rest from being applied")
+	}
+}
+
+// fakeContentful serves a delta sync that fails and the entries a rebuild
+// fetches
+type fakeContentful struct {
+	mu sync.Mutex
+	requests []string
+}
+
+func (f *fakeContentful) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	f.requests = append(f.requests, r.URL.Path + "?" + r.URL.RawQuery)
+	f.mu.Unlock()
+
+	if r.URL.Path == "/spaces/space/environments/master/sync" {
+		if query.Get("sync_token") == "stale" {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"message":"invalid sync token"}`)
+			return
+		}
+
+		io.WriteString(w, `{"items":[],"nextSyncUrl":"https://cdn.contentful.com/sync?sync_token=fresh"}`)
+		return
+	}
+
+	items := map[string]string{
+		"standard": `{"sys":{"id":"vegan"},"fields":{"title":"Vegan","slug":"vegan"}}`,
+		"tags": `{"sys":{"id":"brunch"},"fields":{"title":"Brunch","slug":"brunch"}}`,
+		"location": `{"sys":{"id":"green-kitchen"},"fields":{"name":"Green Kitchen","slug":"green-kitchen"}}`,
+	}
+
+	io.WriteString(w, `{"total":1,"skip":0,"limit":1000,"items":[` + items[query.Get("content_type")] + `]}`)
+}
+
+func (f *fakeContentful) sent(request string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Contains(f.requests, request)
+}
+
+func TestLoadDataRebuildsAfterFailedSync(t *testing.T) {
+	fake := &fakeContentful{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store := NewStore()
+	store.PutLocation(Location{ID: "closed-cafe", Slug: "closed-cafe"})
+	store.SetSyncToken("stale")
+
+	cs := NewContentfulSource(config.Contentful{
+		APIKey: "token",
+		SpaceID: "space",
+		BaseURL: server.URL,
+		Environment: "master",
+	}, store, []string{"en-US"})
+
+	err := cs.loadData(context.Background())
+
+	var status *contentful.StatusError
+
+	if !errors.As(err, &status) || status.StatusCode != http.StatusBadRequest {
+		t.Errorf("got %v, want the failed sync reported", err)
+	}
+
+	if !fake.sent("/spaces/space/environments/master/sync?sync_token=stale") {
+		t.Errorf("didn't try a delta sync, sent %v", fake.requests)
+	}
+
+	snapshot := store.Snapshot()
+
+	if _, ok := snapshot.Locations["green-kitchen"]; !ok || len(snapshot.Locations) != 1 {
+		t.Errorf("didn't rebuild, got locations %v", snapshot.Locations)
+	}
+
+	if _, ok := snapshot.Standards["vegan"]; !ok {
+		t.Errorf("didn't rebuild, got standards %v", snapshot.Standards)
+	}
+
+	if snapshot.SyncToken != "" {
+		t.Errorf("kept sync token %q, want it cleared", snapshot.SyncToken)
+	}
+
+	// with the token cleared the next refresh starts over with an initial sync
+	err = cs.loadData(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fake.sent("/spaces/space/environments/master/sync?initial=true") {
+		t.Errorf("didn't run an initial sync, sent %v", fake.requests)
+	}
+
+	if store.Snapshot().SyncToken != "fresh" {
+		t.Errorf("got sync token %q, want fresh", store.Snapshot().SyncToken)
+	}
+}
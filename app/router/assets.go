@@ -0,0 +1,43 @@
+package router
+
+import (
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"eatingisactivism/app/assets"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveAssets serves the files in public. A fingerprinted name never changes
+// content, so it is cached for good. Anything else is only cached for a while
+// in a release, since fonts and the like are still linked by their plain name.
+func serveAssets(public fs.FS, manifest *assets.Manifest, release bool) gin.HandlerFunc {
+	files := http.FS(public)
+
+	return func(c *gin.Context) {
+		name := strings.TrimPrefix(c.Param("filepath"), "/")
+		original, hashed := manifest.Original(name)
+
+		if hashed {
+			name = original
+		}
+
+		info, err := fs.Stat(public, name)
+
+		// directories aren't listed
+		if err != nil || info.IsDir() {
+			renderHTMLError(c, http.StatusNotFound, "Page not found")
+			return
+		}
+
+		if hashed {
+			c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		} else if release {
+			c.Header("Cache-Control", "public, max-age=3600")
+		}
+
+		c.FileFromFS(name, files)
+	}
+}
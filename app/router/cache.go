@@ -0,0 +1,123 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"eatingisactivism/app/cdn"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cachePolicy is how long a route may be cached, and the tags it is labelled
+// with so it can be purged when its content changes
+type cachePolicy struct {
+	// maxAge is how long browsers may keep a response
+	maxAge time.Duration
+	// edgeMaxAge is how long the CDN may keep a response. It can be long as
+	// webhooks purge what changed.
+	edgeMaxAge time.Duration
+	tags func(c *gin.Context) []string
+	// localized responses depend on the locale. Cloudflare ignores Vary, so
+	// one negotiated from Accept-Language is kept out of the edge cache, or
+	// every visitor would get whichever language was cached first.
+	localized bool
+}
+
+var (
+	// contentCache is for pages built from Contentful
+	contentCache = cachePolicy{
+		maxAge: time.Minute,
+		edgeMaxAge: 24 * time.Hour,
+		localized: true,
+	}
+	// foodsCache is for the food data, which only changes with a deploy
+	foodsCache = cachePolicy{
+		maxAge: time.Hour,
+		edgeMaxAge: 7 * 24 * time.Hour,
+		tags: staticTags(cdn.TagFoods),
+	}
+)
+
+// withTags returns a copy of the policy labelled with tags
+func (p cachePolicy) withTags(tags func(c *gin.Context) []string) cachePolicy {
+	p.tags = tags
+
+	return p
+}
+
+func staticTags(tags ...string) func(c *gin.Context) []string {
+	return func(c *gin.Context) []string {
+		return tags
+	}
+}
+
+// locationTags labels a location page with its slug, and with standards and
+// tags since their names are shown on it
+func locationTags(c *gin.Context) []string {
+	return []string{cdn.LocationTag(c.Param("location")), cdn.TagStandards, cdn.TagTags}
+}
+
+// cacheMiddleware sets Cache-Control, Cache-Tag and Surrogate-Key for the
+// route. Responses are only cached at the edge when edge is set, otherwise
+// they are private to the browser. A localized response whose locale was
+// negotiated by localeMiddleware is private as well and varies on
+// Accept-Language, only ?lang= URLs are cached at the edge. So is a response
+// that sets a cookie, which a shared cache would hand to everyone. Error
+// responses replace Cache-Control with no-store.
+func cacheMiddleware(p cachePolicy, edge bool) gin.HandlerFunc {
+	private := fmt.Sprintf("private, max-age=%d", int(p.maxAge.Seconds()))
+	public := fmt.Sprintf("public, max-age=%d, s-maxage=%d", int(p.maxAge.Seconds()), int(p.edgeMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		negotiated := p.localized && c.GetBool("localeNegotiated")
+		// middleware ahead of this one, like AuthHTML, has already set its
+		// cookies
+		setsCookie := len(c.Writer.Header().Values("Set-Cookie")) > 0
+		cacheControl := private
+
+		if edge && !negotiated && !setsCookie {
+			cacheControl = public
+		}
+
+		if negotiated {
+			addVary(c, "Accept-Language")
+		}
+
+		c.Header("Cache-Control", cacheControl)
+
+		if p.tags != nil {
+			tags := p.tags(c)
+
+			// Cloudflare reads Cache-Tag, Fastly and most others Surrogate-Key
+			c.Header("Cache-Tag", strings.Join(tags, ","))
+			c.Header("Surrogate-Key", strings.Join(tags, " "))
+		}
+
+		c.Next()
+	}
+}
+
+// noStore keeps responses out of every cache, for anything that changes per
+// request or per user
+func noStore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "no-store")
+		c.Next()
+	}
+}
+
+// addVary adds header to Vary, keeping what other middleware put there
+func addVary(c *gin.Context, header string) {
+	for _, value := range c.Writer.Header().Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if http.CanonicalHeaderKey(strings.TrimSpace(existing)) == http.CanonicalHeaderKey(header) {
+				return
+			}
+		}
+	}
+
+	c.Writer.Header().Add("Vary", header)
+}
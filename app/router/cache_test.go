@@ -0,0 +1,174 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCacheMiddlewareNegotiatedLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		locales []string
+		policy cachePolicy
+		edge bool
+		url string
+		acceptLanguage string
+		want string
+		vary bool
+	}{
+		{
+			name: "lang query",
+			locales: []string{"en-US", "de"},
+			policy: contentCache,
+			edge: true,
+			url: "/page?lang=de",
+			acceptLanguage: "de",
+			want: "public",
+		},
+		{
+			name: "accept language",
+			locales: []string{"en-US", "de"},
+			policy: contentCache,
+			edge: true,
+			url: "/page",
+			acceptLanguage: "de",
+			want: "private",
+			vary: true,
+		},
+		{
+			name: "no accept language",
+			locales: []string{"en-US", "de"},
+			policy: contentCache,
+			edge: true,
+			url: "/page",
+			want: "private",
+			vary: true,
+		},
+		{
+			name: "single locale",
+			locales: []string{"en-US"},
+			policy: contentCache,
+			edge: true,
+			url: "/page",
+			acceptLanguage: "de",
+			want: "public",
+		},
+		{
+			name: "not localized",
+			locales: []string{"en-US", "de"},
+			policy: foodsCache,
+			edge: true,
+			url: "/page",
+			acceptLanguage: "de",
+			want: "public",
+		},
+		{
+			name: "edge off",
+			locales: []string{"en-US", "de"},
+			policy: contentCache,
+			url: "/page?lang=de",
+			want: "private",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(localeMiddleware(test.locales))
+			r.GET("/page", cacheMiddleware(test.policy, test.edge), func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString("locale"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+
+			if test.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", test.acceptLanguage)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			cacheControl := w.Header().Get("Cache-Control")
+
+			if !strings.HasPrefix(cacheControl, test.want + ",") {
+				t.Errorf("got Cache-Control %q, want %s", cacheControl, test.want)
+			}
+
+			if test.want == "private" && strings.Contains(cacheControl, "s-maxage") {
+				t.Errorf("got Cache-Control %q, a private response has no s-maxage", cacheControl)
+			}
+
+			if vary := w.Header().Get("Vary") == "Accept-Language"; vary != test.vary {
+				t.Errorf("got Vary %q, want Accept-Language: %v", w.Header().Get("Vary"), test.vary)
+			}
+		})
+	}
+}
+
+// routes that don't depend on the locale, like assets and 404s, don't vary
+// on Accept-Language
+func TestLocaleMiddlewareDoesNotVary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(localeMiddleware([]string{"en-US", "de"}))
+	r.GET("/public/*filepath", func(c *gin.Context) {
+		c.String(http.StatusOK, "body {}")
+	})
+
+	for _, url := range []string{"/public/main.css", "/missing"} {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Accept-Language", "de")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if vary := w.Header().Get("Vary"); vary != "" {
+			t.Errorf("%s got Vary %q", url, vary)
+		}
+	}
+}
+
+func TestCacheMiddlewareSetCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		cookie bool
+		want string
+	}{
+		{name: "sets a cookie", cookie: true, want: "private"},
+		{name: "no cookie", want: "public"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if test.cookie {
+					c.SetCookie("_token", "hash", 60, "/", "", false, true)
+				}
+
+				c.Next()
+			})
+			r.GET("/page", cacheMiddleware(foodsCache, true), func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/page", nil))
+
+			cacheControl := w.Header().Get("Cache-Control")
+
+			if !strings.HasPrefix(cacheControl, test.want + ",") {
+				t.Errorf("got Cache-Control %q, want %s", cacheControl, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package router
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCachedResponses caps how many variants of a route are kept, since
+// filters come from the query string
+const maxCachedResponses = 256
+
+// jsonResponse is a JSON body serialized ahead of time along with its
+// validators
+type jsonResponse struct {
+	body []byte
+	etag string
+	lastModified time.Time
+}
+
+// newJSONResponse serializes v the way the renderer does, with an ETag
+// hashed from the body
+func newJSONResponse(v any, lastModified time.Time) (*jsonResponse, error) {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(v)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+
+	return &jsonResponse{
+		body: buf.Bytes(),
+		etag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+		// HTTP dates only go down to the second
+		lastModified: lastModified.UTC().Truncate(time.Second),
+	}, nil
+}
+
+// serve writes the response, or 304 Not Modified when the request's
+// If-None-Match or If-Modified-Since says the client already has it
+func (r *jsonResponse) serve(c *gin.Context) {
+	c.Header("ETag", r.etag)
+	c.Header("Last-Modified", r.lastModified.Format(http.TimeFormat))
+
+	if r.notModified(c.Request) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json; charset=UTF-8", r.body)
+}
+
+func (r *jsonResponse) notModified(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match wins over If-Modified-Since when both are sent
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, etag := range strings.Split(match, ",") {
+			etag = strings.TrimSpace(etag)
+
+			// compression middleware may weaken the ETag on the way out
+			if etag == "*" || strings.TrimPrefix(etag, "W/") == r.etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+
+	return err == nil && !r.lastModified.After(since)
+}
+
+// jsonCache holds the responses built from the data, so each is serialized
+// once per change rather than on every request
+type jsonCache struct {
+	mu sync.Mutex
+	responses map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	*jsonResponse
+	// version is the version of the data the response was built from
+	version uint64
+}
+
+// get returns the response for key at version, building it from build when
+// the one held is from an older version. A rebuilt response whose body didn't
+// change keeps its ETag and Last-Modified.
+func (jc *jsonCache) get(version uint64, updatedAt time.Time, key string, build func() any) (*jsonResponse, error) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+
+	held, ok := jc.responses[key]
+
+	if ok && held.version == version {
+		return held.jsonResponse, nil
+	}
+
+	response, err := newJSONResponse(build(), updatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ok && held.etag == response.etag {
+		response = held.jsonResponse
+	}
+
+	if jc.responses == nil || (!ok && len(jc.responses) >= maxCachedResponses) {
+		jc.responses = map[string]cachedResponse{}
+	}
+
+	jc.responses[key] = cachedResponse{jsonResponse: response, version: version}
+
+	return response, nil
+}
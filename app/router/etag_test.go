@@ -0,0 +1,206 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestJSONResponseNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	response, err := newJSONResponse(map[string]string{"name": "Green Kitchen"}, modified.Add(500 * time.Millisecond))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Any("/api", response.serve)
+
+	tests := []struct {
+		name string
+		method string
+		header http.Header
+		want int
+	}{
+		{
+			name: "no validators",
+			method: http.MethodGet,
+			want: http.StatusOK,
+		},
+		{
+			name: "matching etag",
+			method: http.MethodGet,
+			header: http.Header{"If-None-Match": {response.etag}},
+			want: http.StatusNotModified,
+		},
+		{
+			name: "weak etag",
+			method: http.MethodGet,
+			header: http.Header{"If-None-Match": {"W/" + response.etag}},
+			want: http.StatusNotModified,
+		},
+		{
+			name: "etag in a list",
+			method: http.MethodGet,
+			header: http.Header{"If-None-Match": {`"stale", ` + response.etag}},
+			want: http.StatusNotModified,
+		},
+		{
+			name: "any etag",
+			method: http.MethodGet,
+			header: http.Header{"If-None-Match": {"*"}},
+			want: http.StatusNotModified,
+		},
+		{
+			name: "other etag",
+			method: http.MethodGet,
+			header: http.Header{"If-None-Match": {`"stale"`}},
+			want: http.StatusOK,
+		},
+		{
+			name: "modified since",
+			method: http.MethodGet,
+			header: http.Header{"If-Modified-Since": {modified.Add(-time.Second).Format(http.TimeFormat)}},
+			want: http.StatusOK,
+		},
+		{
+			name: "not modified since",
+			method: http.MethodGet,
+			header: http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}},
+			want: http.StatusNotModified,
+		},
+		{
+			name: "not modified since on head",
+			method: http.MethodHead,
+			header: http.Header{"If-Modified-Since": {modified.Add(time.Hour).Format(http.TimeFormat)}},
+			want: http.StatusNotModified,
+		},
+		{
+			name: "etag wins over date",
+			method: http.MethodGet,
+			header: http.Header{"If-None-Match": {`"stale"`}, "If-Modified-Since": {modified.Format(http.TimeFormat)}},
+			want: http.StatusOK,
+		},
+		{
+			name: "not a read",
+			method: http.MethodPost,
+			header: http.Header{"If-None-Match": {response.etag}},
+			want: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/api", nil)
+			req.Header = test.header
+
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != test.want {
+				t.Errorf("got %d, want %d", w.Code, test.want)
+			}
+
+			if w.Header().Get("ETag") != response.etag {
+				t.Errorf("got ETag %q, want %q", w.Header().Get("ETag"), response.etag)
+			}
+
+			if w.Header().Get("Last-Modified") != modified.Format(http.TimeFormat) {
+				t.Errorf("got Last-Modified %q", w.Header().Get("Last-Modified"))
+			}
+
+			if test.want == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("got body %q on a 304", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestJSONCacheKeepsValidatorsOfAnUnchangedBody(t *testing.T) {
+	jc := &jsonCache{}
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	builds := 0
+	body := "Green Kitchen"
+
+	build := func() any {
+		builds++
+		return map[string]string{"name": body}
+	}
+
+	held, err := jc.get(1, first, "locations", build)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same version is served from the cache
+	again, _ := jc.get(1, first, "locations", build)
+
+	if again != held || builds != 1 {
+		t.Errorf("rebuilt an unchanged version, %d builds", builds)
+	}
+
+	// a change elsewhere bumps the version but leaves this body alone
+	bumped, _ := jc.get(2, first.Add(time.Hour), "locations", build)
+
+	if builds != 2 {
+		t.Errorf("didn't rebuild a new version, %d builds", builds)
+	}
+
+	if bumped.etag != held.etag || !bumped.lastModified.Equal(first) {
+		t.Errorf("got ETag %s modified %v, want %s modified %v", bumped.etag, bumped.lastModified, held.etag, first)
+	}
+
+	body = "The Green Kitchen"
+	changed, _ := jc.get(3, first.Add(2 * time.Hour), "locations", build)
+
+	if changed.etag == held.etag || !changed.lastModified.Equal(first.Add(2 * time.Hour)) {
+		t.Errorf("got ETag %s modified %v for a changed body", changed.etag, changed.lastModified)
+	}
+}
+
+func TestJSONCacheCap(t *testing.T) {
+	jc := &jsonCache{}
+	now := time.Now()
+
+	build := func() any {
+		return []string{}
+	}
+
+	for i := 0; i < maxCachedResponses; i++ {
+		jc.get(1, now, fmt.Sprint("filter-", i), build)
+	}
+
+	if len(jc.responses) != maxCachedResponses {
+		t.Fatalf("holding %d responses, want %d", len(jc.responses), maxCachedResponses)
+	}
+
+	// rebuilding a key that is already held doesn't count against the cap
+	jc.get(2, now, "filter-0", build)
+
+	if len(jc.responses) != maxCachedResponses {
+		t.Errorf("holding %d responses after rebuilding one, want %d", len(jc.responses), maxCachedResponses)
+	}
+
+	// one past the cap starts over
+	jc.get(2, now, "one-too-many", build)
+
+	if len(jc.responses) != 1 {
+		t.Errorf("holding %d responses past the cap, want 1", len(jc.responses))
+	}
+
+	if _, ok := jc.responses["one-too-many"]; !ok {
+		t.Error("dropped the response that went past the cap")
+	}
+}
@@ -2,57 +2,96 @@ package router
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
 	"io"
+	"log/slog"
 	"strings"
 	"slices"
 	"strconv"
+	"time"
 	"html/template"
+	"io/fs"
 
+	"eatingisactivism/app/assets"
 	"eatingisactivism/app/auth"
+	"eatingisactivism/app/cdn"
+	"eatingisactivism/app/config"
 	"eatingisactivism/app/locations"
 	"eatingisactivism/app/seasons"
+	"eatingisactivism/app/webhooks"
 
 	healthcheck "github.com/RaMin0/gin-health-check"
 	brotli "github.com/anargu/gin-brotli"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"github.com/semihalev/gin-stats"
 	"github.com/unrolled/render"
+	"golang.org/x/text/language"
 )
 
-var (
-	mapboxToken string
-	environment string
-)
-
-func init() {
-	godotenv.Load(".env")
-	mapboxToken = os.Getenv("MAPBOX_TOKEN")
-	environment = os.Getenv("GIN_MODE")
-
-	if (mapboxToken == "") {
-		panic("MAPBOX_TOKEN not found in .env")
-	}
-}
-
 // function takes a string and returns HTML
 // This will be used inside of templates
 func safeHTML(s string) template.HTML {
 	return template.HTML(s)
 }
 
-func staticCacheMiddleware() gin.HandlerFunc {
+// localeMiddleware picks the locale to render content in from the lang query
+// param, then the Accept-Language header, falling back to the default locale.
+// The locale is stored under "locale" in the context, and "localeNegotiated"
+// is set when it came from Accept-Language with more than one to pick from.
+// Only localized routes vary on it, which cacheMiddleware takes care of.
+func localeMiddleware(supported []string) gin.HandlerFunc {
+	tags := make([]language.Tag, 0, len(supported))
+
+	for _, locale := range supported {
+		tags = append(tags, language.Make(locale))
+	}
+
+	matcher := language.NewMatcher(tags)
+
 	return func(c *gin.Context) {
-		if (strings.HasPrefix(c.Request.URL.Path, "/public")) {
-			c.Header("Cache-Control", "public, max-age=31536000")
+		locale := supported[0]
+
+		if lang := c.Query("lang"); lang != "" {
+			_, index, confidence := matcher.Match(language.Make(lang))
+
+			if confidence != language.No {
+				locale = supported[index]
+			}
+		} else {
+			wanted, _, err := language.ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+
+			if err == nil && len(wanted) > 0 {
+				_, index, confidence := matcher.Match(wanted...)
+
+				if confidence != language.No {
+					locale = supported[index]
+				}
+			}
+
+			c.Set("localeNegotiated", len(supported) > 1)
 		}
+
+		c.Set("locale", locale)
 		c.Next()
 	}
 }
 
+// serveJSON serves the response held in cache for key, building it when the
+// data moved on past the version it was built from
+func serveJSON(c *gin.Context, cache *jsonCache, version uint64, updatedAt time.Time, key string, build func() any) {
+	response, err := cache.get(version, updatedAt, key, build)
+
+	if err != nil {
+		renderJSONError(c, http.StatusInternalServerError, "Error encoding response")
+		return
+	}
+
+	response.serve(c)
+}
+
 func renderHTMLError(c *gin.Context, status int, message string) {
+	c.Header("Cache-Control", "no-store")
 	c.HTML(status, "pages/error", gin.H{
 		"status": status,
 		"message": message,
@@ -61,6 +100,7 @@ func renderHTMLError(c *gin.Context, status int, message string) {
 }
 
 func renderJSONError(c *gin.Context, status int, message string) {
+	c.Header("Cache-Control", "no-store")
 	c.JSON(status, gin.H{
 		"status": status,
 		"message": message,
@@ -68,27 +108,90 @@ func renderJSONError(c *gin.Context, status int, message string) {
 	c.Abort()
 }
 
-func Router() *gin.Engine {
+// New builds the router, serving locations from svc and webhooks through
+// queue. Templates are read from the templates directory of files and static
+// files served from its public directory.
+func New(cfg config.Config, svc *locations.Service, a *auth.Auth, queue *webhooks.Queue, files fs.FS) (*gin.Engine, error) {
+	if (cfg.MapboxToken == "") {
+		slog.Warn("MAPBOX_TOKEN not found in .env, the map won't load")
+	}
+
+	public, err := fs.Sub(files, "public")
+
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := assets.NewManifest(public)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// files read from disk change under the watchers, so they are linked by
+	// their plain names
+	asset := func(name string) string {
+		return "/public/" + strings.TrimPrefix(name, "/")
+	}
+
+	if !cfg.DevAssets {
+		asset = func(name string) string {
+			return "/public/" + manifest.Hashed(name)
+		}
+	}
+
 	r := gin.Default()
 	r.SetFuncMap(template.FuncMap{
 		"safeHTML": safeHTML,
+		"richText": svc.RichText,
+		"asset": asset,
 	})
-	r.LoadHTMLGlob("templates/**/*.tmpl")
+
+	// gin only reloads templates on every request when it loads them from a
+	// glob on disk
+	if cfg.DevAssets {
+		r.LoadHTMLGlob("templates/**/*.tmpl")
+	} else {
+		templates, err := template.New("").Funcs(r.FuncMap).ParseFS(files, "templates/*/*.tmpl")
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.SetHTMLTemplate(templates)
+	}
 
 	renderer := render.New(render.Options{
 		Extensions: []string{".tmpl"},
 		Funcs: []template.FuncMap{
 			{
 				"safeHTML": safeHTML,
+				"richText": svc.RichText,
+				"asset": asset,
 			},
 		},
+		Directory: "templates",
+		FileSystem: render.FS(files),
 		IndentJSON: true,
-		IsDevelopment: environment != "release",
+		IsDevelopment: cfg.DevAssets,
 		Layout: "layout",
 	})
 
 	r.Use(brotli.Brotli(brotli.DefaultCompression))
 	r.Use(healthcheck.Default())
+	r.Use(localeMiddleware(svc.Locales()))
+
+	// drafts mustn't end up in a shared cache
+	edge := cfg.EdgeCache && !svc.Preview()
+	contentCached := func(tags func(c *gin.Context) []string) gin.HandlerFunc {
+		return cacheMiddleware(contentCache.withTags(tags), edge)
+	}
+	foodsCached := cacheMiddleware(foodsCache, edge)
+
+	// JSON responses are serialized once per change to the data. The food
+	// data only changes with a deploy, so it is as old as the process.
+	var locationsJSON, foodsJSON jsonCache
+	bootedAt := time.Now()
 
 	r.NoRoute(func(c *gin.Context) {
 		// of the request is to the /api path, return a JSON error
@@ -100,22 +203,17 @@ func Router() *gin.Engine {
 		renderHTMLError(c, http.StatusNotFound, "Page not found")
 	})
 
-	// if GIN_MODE is release, we need to compress static assets and set cache headers
-	if gin.Mode() == gin.ReleaseMode {
-		r.Use(staticCacheMiddleware())
-	}
-
-	r.Static("/public", "./public")
+	r.GET("/public/*filepath", serveAssets(public, manifest, cfg.Release()))
 
-	r.GET("/login", func(c *gin.Context) {
+	r.GET("/login", noStore(), func(c *gin.Context) {
 		renderer.HTML(c.Writer, http.StatusOK, "pages/login", gin.H{})
 	})
 
-	r.POST("/login", func(c *gin.Context) {
+	r.POST("/login", noStore(), func(c *gin.Context) {
 		pass := c.PostForm("password")
-		passHash := auth.HashValue(pass)
+		passHash := a.HashValue(pass)
 
-		if auth.IsPasswordValid(passHash) {
+		if a.IsPasswordValid(passHash) {
 			c.SetCookie("_token", passHash, int(60 * 60 * 24), "/", "", false, true)
 			c.Redirect(http.StatusFound, "/")
 		} else {
@@ -123,44 +221,52 @@ func Router() *gin.Engine {
 		}
 	})
 
-	authorized := r.Group("/", auth.AuthHTML())
+	authorized := r.Group("/", a.AuthHTML())
 	{
-		authorized.GET("/", func(c *gin.Context) {
-			locs := locations.GetLocations()
+		authorized.GET("/", contentCached(staticTags(cdn.TagLocations, cdn.TagStandards, cdn.TagTags, cdn.TagFoods)), func(c *gin.Context) {
+			locale := c.GetString("locale")
+			locs := svc.GetLocations().Localized(locale)
 			locationJSON, _ := json.Marshal(locs)
 
 			renderer.HTML(c.Writer, http.StatusOK, "pages/home", gin.H{
+				"lang": locale,
 				"locations": locs,
 				"states": seasons.States,
 				"seasons": seasons.Seasons,
-				"standards": locations.GetStandards(),
-				"tags": locations.GetTags(),
+				"standards": svc.GetStandards().Localized(locale),
+				"tags": svc.GetTags().Localized(locale),
 				"locationsJSON": string(locationJSON),
-				"mapboxToken": mapboxToken,
+				"mapboxToken": cfg.MapboxToken,
 			})
 		})
 
-		authorized.GET("/locations", func(c *gin.Context) {
+		authorized.GET("/locations", contentCached(staticTags(cdn.TagLocations, cdn.TagStandards, cdn.TagTags)), func(c *gin.Context) {
+			locale := c.GetString("locale")
+
 			renderer.HTML(c.Writer, http.StatusOK, "pages/locations", gin.H{
-				"locations": locations.GetLocations(),
+				"lang": locale,
+				"locations": svc.GetLocations().Localized(locale),
 			})
 		})
 
-		authorized.GET("/locations/:location", func(c *gin.Context) {
+		authorized.GET("/locations/:location", contentCached(locationTags), func(c *gin.Context) {
 			locationSlug := c.Param("location")
-			location := locations.GetLocationBySlug(locationSlug)
+			location := svc.GetLocationBySlug(locationSlug)
 
 			if (locationSlug == "" || location.Slug == "") {
 				renderHTMLError(c, http.StatusNotFound, "Page not found")
 				return
 			}
 
+			locale := c.GetString("locale")
+
 			renderer.HTML(c.Writer, http.StatusOK, "pages/location-single", gin.H{
-				"location": location,
+				"lang": locale,
+				"location": location.Localized(locale),
 			})
 		})
 
-		authorized.GET("/foods", func(c *gin.Context) {
+		authorized.GET("/foods", foodsCached, func(c *gin.Context) {
 			// log the request
 			state := c.Query("state") // string
 			season := c.Query("season") // int
@@ -206,15 +312,29 @@ func Router() *gin.Engine {
 		})
 	}
 
-	v1 := r.Group("/api/v1", auth.AuthJSON())
+	v1 := r.Group("/api/v1", a.AuthJSON())
 	{
 		v1.Use(stats.RequestStats())
 
-		v1.GET("/stats", func(c *gin.Context) {
+		v1.GET("/stats", noStore(), func(c *gin.Context) {
 			renderer.JSON(c.Writer, http.StatusOK, stats.Report())
 		})
 
-		v1.GET("/locations", func(c *gin.Context) {
+		// reports when content was last refreshed from Contentful
+		v1.GET("/status", noStore(), func(c *gin.Context) {
+			renderer.JSON(c.Writer, http.StatusOK, gin.H{
+				"refresh": svc.GetRefreshStatus(),
+			})
+		})
+
+		v1.GET("/locations", contentCached(staticTags(cdn.TagLocations, cdn.TagStandards, cdn.TagTags)), func(c *gin.Context) {
+			// a preview has drafts and unpublished changes in it, which
+			// mustn't get out through the API
+			if svc.Preview() {
+				renderJSONError(c, http.StatusNotFound, "Locations are not available in preview mode")
+				return
+			}
+
 			tagsParam := c.Query("tags")
 			tags := []string{}
 
@@ -229,22 +349,34 @@ func Router() *gin.Engine {
 				standards = strings.Split(standardsParam, ",")
 			}
 
-			var locs = locations.LocationMap{}
+			locale := c.GetString("locale")
+			version, updatedAt := svc.Version()
 
-			if (len(tags) != 0 || len(standards) != 0) {
-				locs = locations.FilterLocations(standards, tags)
-			} else {
-				locs = locations.GetLocations()
-			}
+			serveJSON(c, &locationsJSON, version, updatedAt, locale + "|" + standardsParam + "|" + tagsParam, func() any {
+				var locs = locations.LocationMap{}
+
+				if (len(tags) != 0 || len(standards) != 0) {
+					locs = svc.FilterLocations(standards, tags)
+				} else {
+					locs = svc.GetLocations()
+				}
 
-			renderer.JSON(c.Writer, http.StatusOK, locs)
+				return locs.Localized(locale)
+			})
 		})
 
-		v1.GET("/foods", func(c *gin.Context) {
-			renderer.JSON(c.Writer, http.StatusOK, seasons.GetFoods())
+		// recent webhook deliveries and what became of them
+		v1.GET("/webhooks", noStore(), func(c *gin.Context) {
+			renderer.JSON(c.Writer, http.StatusOK, queue.Deliveries())
 		})
 
-		v1.GET("/seasons/:season", func(c *gin.Context) {
+		v1.GET("/foods", foodsCached, func(c *gin.Context) {
+			serveJSON(c, &foodsJSON, 0, bootedAt, c.Request.URL.Path, func() any {
+				return seasons.GetFoods()
+			})
+		})
+
+		v1.GET("/seasons/:season", foodsCached, func(c *gin.Context) {
 			season := c.Param("season")
 
 			if (season == "") {
@@ -259,12 +391,12 @@ func Router() *gin.Engine {
 				return
 			}
 
-			foods := seasons.GetFoodsBySeason(seasonInt)
-
-			renderer.JSON(c.Writer, http.StatusOK, foods)
+			serveJSON(c, &foodsJSON, 0, bootedAt, c.Request.URL.Path, func() any {
+				return seasons.GetFoodsBySeason(seasonInt)
+			})
 		})
 
-		v1.GET("/states/:state", func(c *gin.Context) {
+		v1.GET("/states/:state", foodsCached, func(c *gin.Context) {
 			state := c.Param("state")
 
 			if (state == "") {
@@ -272,12 +404,12 @@ func Router() *gin.Engine {
 				return
 			}
 
-			foods := seasons.GetFoodsByState(state)
-
-			renderer.JSON(c.Writer, http.StatusOK, foods)
+			serveJSON(c, &foodsJSON, 0, bootedAt, c.Request.URL.Path, func() any {
+				return seasons.GetFoodsByState(state)
+			})
 		})
 
-		v1.GET("/states/:state/seasons/:season", func(c *gin.Context) {
+		v1.GET("/states/:state/seasons/:season", foodsCached, func(c *gin.Context) {
 			state := c.Param("state")
 			season := c.Param("season")
 
@@ -305,13 +437,17 @@ func Router() *gin.Engine {
 				return
 			}
 
-			foods := seasons.GetFoodsByStateAndSeason(state, seasonInt)
-
-			renderer.JSON(c.Writer, http.StatusOK, foods)
+			serveJSON(c, &foodsJSON, 0, bootedAt, state + "/" + season, func() any {
+				return seasons.GetFoodsByStateAndSeason(state, seasonInt)
+			})
 		})
+	}
 
+	// webhooks are signed by Contentful instead of carrying the site password
+	signed := r.Group("/api/v1", a.AuthWebhook())
+	{
 		// route to accept webhook from contentful
-		v1.POST("/webhook", func(c *gin.Context) {
+		signed.POST("/webhook", noStore(), func(c *gin.Context) {
 
 			jsonData, err := io.ReadAll(c.Request.Body)
 			topic := c.GetHeader("X-Contentful-Topic")
@@ -321,9 +457,21 @@ func Router() *gin.Engine {
 				return
 			}
 
-			locations.HandleWebhook(topic, jsonData)
+			delivery, err := queue.Enqueue(topic, jsonData)
+
+			if errors.Is(err, webhooks.ErrInvalidPayload) {
+				renderJSONError(c, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			if err != nil {
+				renderJSONError(c, http.StatusServiceUnavailable, err.Error())
+				return
+			}
+
+			renderer.JSON(c.Writer, http.StatusAccepted, delivery)
 		})
 	}
 
-	return r
+	return r, nil
 }
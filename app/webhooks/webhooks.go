@@ -0,0 +1,385 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"eatingisactivism/app/contentful"
+)
+
+const (
+	StatusQueued string = "queued"
+	StatusProcessing string = "processing"
+	StatusRetrying string = "retrying"
+	StatusSucceeded string = "succeeded"
+	StatusFailed string = "failed"
+	// StatusSuperseded marks a delivery replaced by a newer one for the same
+	// entry before it was processed
+	StatusSuperseded string = "superseded"
+
+	defaultQueueSize = 256
+	defaultMaxAttempts = 5
+	defaultRetryBackoff = 2 * time.Second
+	historySize = 100
+)
+
+var (
+	ErrInvalidPayload = errors.New("webhooks: payload is not a Contentful entry or asset")
+	ErrQueueFull = errors.New("webhooks: queue is full")
+	ErrQueueStopped = errors.New("webhooks: queue is stopped")
+)
+
+// ProcessFunc handles a single webhook, returning an error to have it retried.
+// Errors wrapped with Permanent fail the webhook straight away. ctx is
+// cancelled when the queue is stopped and its grace period runs out.
+type ProcessFunc func(ctx context.Context, topic string, body []byte) error
+
+// PermanentError is an error retrying won't fix
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as one retrying won't fix, so the webhook fails on its
+// first attempt
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &PermanentError{Err: err}
+}
+
+// permanent reports whether a webhook for topic that failed with err would
+// fail again. An entry Contentful sent that we can't decode stays that way
+// until the next webhook for it. An entry that is gone only stays gone when
+// the webhook took it away, the Delivery API lags a few seconds behind a
+// publish.
+func permanent(topic string, err error) bool {
+	var permanentErr *PermanentError
+	var decodeErr *contentful.DecodeError
+
+	if errors.As(err, &permanentErr) || errors.As(err, &decodeErr) {
+		return true
+	}
+
+	if !errors.Is(err, contentful.ErrNotFound) {
+		return false
+	}
+
+	switch topic {
+	case contentful.WebhookUnpublish, contentful.WebhookArchive, contentful.WebhookDelete,
+		contentful.WebhookAssetUnpublish, contentful.WebhookAssetArchive, contentful.WebhookAssetDelete:
+		return true
+	}
+
+	return false
+}
+
+// Delivery is a webhook we received and what became of it
+type Delivery struct {
+	ID string `json:"id"`
+	Topic string `json:"topic"`
+	EntryID string `json:"entryId"`
+	ContentType string `json:"contentType,omitempty"`
+	Status string `json:"status"`
+	Attempts int `json:"attempts"`
+	Error string `json:"error,omitempty"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type job struct {
+	topic string
+	body []byte
+	delivery *Delivery
+}
+
+// Queue processes webhooks one at a time in the background. Webhooks for an
+// entry that is still waiting replace the waiting one, so a burst of publishes
+// of the same entry only fetches it once. Failures are retried with
+// exponential backoff.
+type Queue struct {
+	process ProcessFunc
+	maxAttempts int
+	backoff time.Duration
+
+	mu sync.Mutex
+	pending map[string]*job
+	ready chan string
+	retries map[*time.Timer]struct{}
+	history []*Delivery
+	nextID int
+	stopped bool
+
+	ctx context.Context
+	cancel context.CancelFunc
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewQueue(process ProcessFunc) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Queue{
+		process: process,
+		maxAttempts: defaultMaxAttempts,
+		backoff: defaultRetryBackoff,
+		pending: map[string]*job{},
+		ready: make(chan string, defaultQueueSize),
+		retries: map[*time.Timer]struct{}{},
+		ctx: ctx,
+		cancel: cancel,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// Start runs the worker until Stop is called
+func (q *Queue) Start() {
+	go q.work()
+}
+
+// Stop stops taking webhooks, lets the one being processed finish and waits
+// for the worker to exit or ctx to be done, at which point the one being
+// processed is cancelled. Webhooks still waiting are dropped, a full resync
+// picks their changes up.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+
+	if q.stopped {
+		q.mu.Unlock()
+		return nil
+	}
+
+	q.stopped = true
+
+	for timer := range q.retries {
+		timer.Stop()
+	}
+
+	q.mu.Unlock()
+
+	close(q.stop)
+
+	select {
+	case <-q.done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		return ctx.Err()
+	}
+}
+
+// Enqueue queues a webhook. The returned delivery is a copy of its state at
+// the time it was queued.
+func (q *Queue) Enqueue(topic string, body []byte) (Delivery, error) {
+	var webhook contentful.ContentfulWebhook
+
+	err := json.Unmarshal(body, &webhook)
+
+	if err != nil || topic == "" || webhook.Sys.ID == "" {
+		return Delivery{}, ErrInvalidPayload
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped {
+		return Delivery{}, ErrQueueStopped
+	}
+
+	now := time.Now()
+	q.nextID++
+
+	delivery := &Delivery{
+		ID: strconv.Itoa(q.nextID),
+		Topic: topic,
+		EntryID: webhook.Sys.ID,
+		ContentType: webhook.Sys.ContentType.Sys.ID,
+		Status: StatusQueued,
+		ReceivedAt: now,
+		UpdatedAt: now,
+	}
+
+	key := webhook.Sys.ID
+
+	if waiting, ok := q.pending[key]; ok {
+		waiting.delivery.Status = StatusSuperseded
+		waiting.delivery.UpdatedAt = now
+		waiting.topic = topic
+		waiting.body = body
+		waiting.delivery = delivery
+		q.remember(delivery)
+
+		return *delivery, nil
+	}
+
+	select {
+	case q.ready <- key:
+	default:
+		return Delivery{}, ErrQueueFull
+	}
+
+	q.pending[key] = &job{
+		topic: topic,
+		body: body,
+		delivery: delivery,
+	}
+	q.remember(delivery)
+
+	return *delivery, nil
+}
+
+// Deliveries returns the most recent deliveries, newest first
+func (q *Queue) Deliveries() []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	deliveries := make([]Delivery, 0, len(q.history))
+
+	for i := len(q.history) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, *q.history[i])
+	}
+
+	return deliveries
+}
+
+// remember adds a delivery to the history, dropping the oldest once full.
+// Must be called with mu held.
+func (q *Queue) remember(delivery *Delivery) {
+	q.history = append(q.history, delivery)
+
+	if len(q.history) > historySize {
+		q.history = q.history[len(q.history) - historySize:]
+	}
+}
+
+func (q *Queue) work() {
+	defer close(q.done)
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case key := <-q.ready:
+			// select picks at random when both are ready, so a stopped
+			// queue could otherwise keep taking webhooks
+			select {
+			case <-q.stop:
+				return
+			default:
+			}
+
+			q.run(key)
+		}
+	}
+}
+
+func (q *Queue) run(key string) {
+	q.mu.Lock()
+	current, ok := q.pending[key]
+
+	if !ok {
+		q.mu.Unlock()
+		return
+	}
+
+	delete(q.pending, key)
+	current.delivery.Status = StatusProcessing
+	current.delivery.Attempts++
+	current.delivery.UpdatedAt = time.Now()
+	q.mu.Unlock()
+
+	err := q.safeProcess(current.topic, current.body)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	current.delivery.UpdatedAt = time.Now()
+
+	if err == nil {
+		current.delivery.Status = StatusSucceeded
+		current.delivery.Error = ""
+		return
+	}
+
+	current.delivery.Error = err.Error()
+
+	if current.delivery.Attempts >= q.maxAttempts || q.stopped || permanent(current.topic, err) {
+		current.delivery.Status = StatusFailed
+		slog.Error("webhook failed", "deliveryID", current.delivery.ID, "topic", current.topic, "entryID", current.delivery.EntryID, "contentType", current.delivery.ContentType, "attempts", current.delivery.Attempts, "err", err)
+		return
+	}
+
+	current.delivery.Status = StatusRetrying
+	slog.Warn("webhook failed, retrying", "deliveryID", current.delivery.ID, "topic", current.topic, "entryID", current.delivery.EntryID, "contentType", current.delivery.ContentType, "attempts", current.delivery.Attempts, "err", err)
+	q.scheduleRetry(key, current, err)
+}
+
+// scheduleRetry puts a failed job back once its backoff has passed, unless a
+// newer webhook for the entry arrived in the meantime. Must be called with mu
+// held.
+func (q *Queue) scheduleRetry(key string, retry *job, err error) {
+	wait := q.backoff << (retry.delivery.Attempts - 1)
+
+	var rateLimit *contentful.RateLimitError
+
+	if errors.As(err, &rateLimit) {
+		wait = max(wait, rateLimit.RetryAfter)
+	}
+
+	var timer *time.Timer
+
+	timer = time.AfterFunc(wait, func() {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+
+		delete(q.retries, timer)
+
+		if q.stopped {
+			return
+		}
+
+		if _, ok := q.pending[key]; ok {
+			retry.delivery.Status = StatusSuperseded
+			retry.delivery.UpdatedAt = time.Now()
+			return
+		}
+
+		select {
+		case q.ready <- key:
+			q.pending[key] = retry
+		default:
+			retry.delivery.Status = StatusFailed
+			retry.delivery.Error = ErrQueueFull.Error()
+			retry.delivery.UpdatedAt = time.Now()
+		}
+	})
+
+	q.retries[timer] = struct{}{}
+}
+
+func (q *Queue) safeProcess(topic string, body []byte) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("webhook panicked: %v", p)
+		}
+	}()
+
+	return q.process(q.ctx, topic, body)
+}
@@ -0,0 +1,164 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"eatingisactivism/app/contentful"
+)
+
+func entryBody(id string) []byte {
+	return []byte(fmt.Sprintf(`{"sys":{"id":%q,"type":"Entry","contentType":{"sys":{"id":"location"}}}}`, id))
+}
+
+// waitForStatus waits for the newest delivery to reach status
+func waitForStatus(t *testing.T, q *Queue, status string) Delivery {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for time.Now().Before(deadline) {
+		deliveries := q.Deliveries()
+
+		if len(deliveries) > 0 && deliveries[0].Status == status {
+			return deliveries[0]
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("delivery never became %s: %+v", status, q.Deliveries())
+	return Delivery{}
+}
+
+func TestQueuePermanentErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		topic string
+		err error
+	}{
+		{name: "decode error", topic: contentful.WebhookPublish, err: fmt.Errorf("decoding location: %w", &contentful.DecodeError{Err: errors.New("bad json")})},
+		{name: "not found on unpublish", topic: contentful.WebhookUnpublish, err: contentful.NotFoundError("location", "abc")},
+		{name: "not found on asset delete", topic: contentful.WebhookAssetDelete, err: contentful.NotFoundError("asset", "abc")},
+		{name: "marked permanent", topic: contentful.WebhookPublish, err: Permanent(errors.New("not loading from Contentful"))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls atomic.Int32
+
+			q := NewQueue(func(ctx context.Context, topic string, body []byte) error {
+				calls.Add(1)
+				return test.err
+			})
+			q.backoff = time.Millisecond
+			q.Start()
+			defer q.Stop(context.Background())
+
+			_, err := q.Enqueue(test.topic, entryBody("abc"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			delivery := waitForStatus(t, q, StatusFailed)
+
+			// give a wrongly scheduled retry the chance to run
+			time.Sleep(20 * time.Millisecond)
+
+			if delivery.Attempts != 1 || calls.Load() != 1 {
+				t.Errorf("processed %d times over %d attempts, want once", calls.Load(), delivery.Attempts)
+			}
+		})
+	}
+}
+
+func TestQueueRetriesOtherErrors(t *testing.T) {
+	var calls atomic.Int32
+
+	q := NewQueue(func(ctx context.Context, topic string, body []byte) error {
+		if calls.Add(1) < 3 {
+			return errors.New("connection reset")
+		}
+
+		return nil
+	})
+	q.backoff = time.Millisecond
+	q.Start()
+	defer q.Stop(context.Background())
+
+	_, err := q.Enqueue("ContentManagement.Entry.publish", entryBody("abc"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := waitForStatus(t, q, StatusSucceeded)
+
+	if delivery.Attempts != 3 {
+		t.Errorf("succeeded after %d attempts, want 3", delivery.Attempts)
+	}
+}
+
+// the Delivery API can lag a few seconds behind a publish webhook, so a
+// publish that isn't found yet is retried
+func TestQueueRetriesPublishNotFound(t *testing.T) {
+	for _, topic := range []string{contentful.WebhookPublish, contentful.WebhookUnarchive, contentful.WebhookAssetPublish} {
+		t.Run(topic, func(t *testing.T) {
+			var calls atomic.Int32
+
+			q := NewQueue(func(ctx context.Context, topic string, body []byte) error {
+				if calls.Add(1) == 1 {
+					return fmt.Errorf("fetching location abc: %w", contentful.NotFoundError("location", "abc"))
+				}
+
+				return nil
+			})
+			q.backoff = time.Millisecond
+			q.Start()
+			defer q.Stop(context.Background())
+
+			_, err := q.Enqueue(topic, entryBody("abc"))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			delivery := waitForStatus(t, q, StatusSucceeded)
+
+			if delivery.Attempts != 2 {
+				t.Errorf("succeeded after %d attempts, want 2", delivery.Attempts)
+			}
+		})
+	}
+}
+
+func TestQueueStopTakesNoMoreWork(t *testing.T) {
+	var calls atomic.Int32
+
+	q := NewQueue(func(ctx context.Context, topic string, body []byte) error {
+		calls.Add(1)
+		return nil
+	})
+
+	for i := 0; i < 10; i++ {
+		_, err := q.Enqueue("ContentManagement.Entry.publish", entryBody(fmt.Sprintf("entry-%d", i)))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// stopped before the worker runs, so nothing waiting is processed
+	close(q.stop)
+	q.Start()
+	<-q.done
+
+	if calls.Load() != 0 {
+		t.Errorf("processed %d webhooks after stopping", calls.Load())
+	}
+}
@@ -1,68 +1,147 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"fmt"
+	"syscall"
+	"time"
 
+	"eatingisactivism/app/auth"
+	"eatingisactivism/app/cdn"
+	"eatingisactivism/app/config"
+	"eatingisactivism/app/locations"
 	"eatingisactivism/app/router"
+	"eatingisactivism/app/webhooks"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
-func purgeCloudflare() {
-	fmt.Println("Purging Cloudflare cache")
-	godotenv.Load(".env")
-	token := os.Getenv("CLOUDFLARE_TOKEN")
-	url := os.Getenv("CLOUDFLARE_CACHE_URL")
+// server timeouts, generous enough for slow clients on the map page but short
+// enough that a stuck connection doesn't hold up shutdown
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout = 30 * time.Second
+	writeTimeout = 60 * time.Second
+	idleTimeout = 120 * time.Second
+)
+
+func main() {
+	cfg, err := config.Load()
 
-	if (token == "" || url == "") {
-		fmt.Println("CLOUDFLARE_TOKEN or CLOUDFLARE_CACHE_URL not found in .env")
-		return
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+		os.Exit(1)
 	}
 
-	reqBody := []byte(`{"purge_everything":true}`)
+	// ctx is done on the first SIGTERM or SIGINT
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+	var purger cdn.Purger = cdn.Noop{}
 
-	if err != nil {
-		fmt.Println("Error: ", err)
-		return
+	if cfg.Cloudflare.Token != "" {
+		purger, err = cdn.NewCloudflare(cfg.Cloudflare)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+			os.Exit(1)
+		}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer " + token)
+	if cfg.Release() {
+		gin.SetMode(gin.ReleaseMode)
+
+		// templates and assets may have changed with the deploy
+		err = purger.PurgeEverything(ctx)
 
-	client := &http.Client{}
-	_, err = client.Do(req)
+		if err != nil {
+			slog.Error("purging the CDN failed", "err", err)
+		}
+	}
+
+	svc, err := locations.New(cfg.Locations, purger)
 
 	if err != nil {
-		fmt.Println("Error: ", err)
-		return
+		fmt.Fprintf(os.Stderr, "loading locations: %v\n", err)
+		os.Exit(1)
 	}
 
-	return
-}
+	svc.Start(ctx)
 
-func main() {
-	godotenv.Load(".env")
-	port := os.Getenv("PORT")
-	mode := os.Getenv("GIN_MODE")
+	queue := webhooks.NewQueue(func(ctx context.Context, topic string, body []byte) error {
+		err := svc.HandleWebhook(ctx, topic, body)
 
-	if mode == "release" {
-		gin.SetMode(gin.ReleaseMode)
-		purgeCloudflare()
+		// without Contentful there is nothing a retry could fetch
+		if errors.Is(err, locations.ErrNoContentful) {
+			return webhooks.Permanent(err)
+		}
+
+		return err
+	})
+	queue.Start()
+
+	var files fs.FS = web
+
+	if cfg.DevAssets {
+		files = os.DirFS(".")
 	}
 
-	if port == "" {
-		port = "8080"
+	r, err := router.New(cfg, svc, auth.New(cfg), queue, files)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "building router: %v\n", err)
+		os.Exit(1)
 	}
 
-	r := router.Router()
 	r.ForwardedByClientIP = true
 	r.SetTrustedProxies([]string{"127.0.0.1"})
 
-	r.Run(":" + port)
-}
\ No newline at end of file
+	server := &http.Server{
+		Addr: ":" + cfg.Port,
+		Handler: r,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout: readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout: idleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		slog.Info("listening", "addr", server.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		slog.Error("server failed", "err", err)
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// a second signal kills the process rather than waiting out the grace
+	// period
+	stop()
+	slog.Info("shutting down", "grace", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// stop taking requests first, so no webhook is queued after the queue
+	// has stopped
+	err = server.Shutdown(shutdownCtx)
+	err = errors.Join(err, queue.Stop(shutdownCtx), svc.Stop(shutdownCtx))
+
+	if err != nil {
+		slog.Error("shutdown didn't finish cleanly", "err", err)
+		os.Exit(1)
+	}
+
+	slog.Info("shut down")
+}
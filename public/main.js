@@ -1,5 +1,6 @@
-var eia=function(){const LOADED_SCRIPTS=new Set,LOADED_STYLES=new Set,markers=new Map;let locations=null,mapboxToken=null,Mapbox=null,debugMode=!1,filterStandards=new Set,filterTags=new Set;function setLocations(locs){locations=locs}function setMapboxToken(token){mapboxToken=token}function filterLocations(){if(debugMode)console.debug("Filtering locations..."),console.debug("Filter standards:",filterStandards),console.debug("Filter tags:",filterTags);Object.keys(locations).forEach((slug)=>{const location=locations[slug];if(debugMode)console.debug("Filtering location:",location.slug),console.log(location);const marker=markers.get(location.slug),hasStandard=filterStandards.size===0||filterStandards.has(location.standard.slug),hasTags=filterTags.size===0||location.tags.some((tag)=>filterTags.has(tag.slug));if(hasStandard&&hasTags)marker.addTo(Mapbox);else marker.remove()})}function waitForLibrary(lib,callback,timeout){if(window[lib]){if(debugMode)console.debug(`${lib} is available`);callback()}else{if(debugMode)console.warn(`${lib} is not available yet, waiting...`);setTimeout(()=>{waitForLibrary(lib,callback)},timeout)}}function documentReady(fn){document.addEventListener("DOMContentLoaded",()=>{if(document.readyState==="interactive"||document.readyState==="complete"){if(debugMode)console.debug("Document is ready");fn()}})}function isValidURL(url){try{return new URL(url),!0}catch(e){return!1}}function injectJS(url){if(!isValidURL(url)){if(debugMode)console.error("Invalid URL:",url);return}if(debugMode)console.debug("Injecting library:",url);if(LOADED_SCRIPTS.has(url)){console.warn("Library already loaded, skipping:",url);return}const script=document.createElement("script");script.type="text/javascript",script.src=url,document.head.appendChild(script),LOADED_SCRIPTS.add(url)}function injectCSS(url){if(!isValidURL(url)){if(debugMode)console.error("Invalid URL:",url);return}if(debugMode)console.debug("Injecting CSS:",url);if(LOADED_STYLES.has(url)){console.warn("CSS already loaded, skipping:",url);return}const link=document.createElement("link");link.rel="stylesheet",link.href=url,document.head.appendChild(link),LOADED_STYLES.add(url)}function addMapLocations(){Object.keys(locations).forEach((slug)=>{const location=locations[slug],el=document.createElement("div"),isPatagonia=location.tags.includes("patagonia")?"patagonia-provisions":"";el.className=`marker ${location.standard} ${isPatagonia}`;const marker=new mapboxgl.Marker(el).setLngLat([location.lng,location.lat]).setPopup(new mapboxgl.Popup().setHTML(`
+var eia=function(){const LOADED_SCRIPTS=new Set,LOADED_STYLES=new Set,markers=new Map;let locations=null,mapboxToken=null,Mapbox=null,debugMode=!1,filterStandards=new Set,filterTags=new Set;function setLocations(locs){locations=locs}function setMapboxToken(token){mapboxToken=token}function filterLocations(){if(debugMode)console.debug("Filtering locations..."),console.debug("Filter standards:",filterStandards),console.debug("Filter tags:",filterTags);Object.keys(locations).forEach((slug)=>{const location=locations[slug];if(debugMode)console.debug("Filtering location:",location.slug),console.log(location);const marker=markers.get(location.slug),hasStandard=filterStandards.size===0||filterStandards.has(location.standard.slug),hasTags=filterTags.size===0||location.tags.some((tag)=>filterTags.has(tag.slug));if(hasStandard&&hasTags)marker.addTo(Mapbox);else marker.remove()})}function waitForLibrary(lib,callback,timeout){if(window[lib]){if(debugMode)console.debug(`${lib} is available`);callback()}else{if(debugMode)console.warn(`${lib} is not available yet, waiting...`);setTimeout(()=>{waitForLibrary(lib,callback)},timeout)}}function documentReady(fn){document.addEventListener("DOMContentLoaded",()=>{if(document.readyState==="interactive"||document.readyState==="complete"){if(debugMode)console.debug("Document is ready");fn()}})}function isValidURL(url){try{return new URL(url),!0}catch(e){return!1}}function injectJS(url){if(!isValidURL(url)){if(debugMode)console.error("Invalid URL:",url);return}if(debugMode)console.debug("Injecting library:",url);if(LOADED_SCRIPTS.has(url)){console.warn("Library already loaded, skipping:",url);return}const script=document.createElement("script");script.type="text/javascript",script.src=url,document.head.appendChild(script),LOADED_SCRIPTS.add(url)}function injectCSS(url){if(!isValidURL(url)){if(debugMode)console.error("Invalid URL:",url);return}if(debugMode)console.debug("Injecting CSS:",url);if(LOADED_STYLES.has(url)){console.warn("CSS already loaded, skipping:",url);return}const link=document.createElement("link");link.rel="stylesheet",link.href=url,document.head.appendChild(link),LOADED_STYLES.add(url)}function imageUrl(asset,width,height){const url=new URL(asset.url);if(url.searchParams.set("w",width),url.searchParams.set("fm","webp"),height)url.searchParams.set("h",height),url.searchParams.set("fit","fill");return url.toString()}function escapeAttribute(text){return String(text??"").replace(/&/g,"&amp;").replace(/"/g,"&quot;").replace(/</g,"&lt;").replace(/>/g,"&gt;")}function popupImage(location){const image=location.heroImage;if(!image||!image.contentType.startsWith("image/"))return"";return`<img class="location-popup-image" src="${escapeAttribute(imageUrl(image,600,338))}" alt="${escapeAttribute(image.description)}" loading="lazy">`}function addMapLocations(){Object.keys(locations).forEach((slug)=>{const location=locations[slug],el=document.createElement("div"),isPatagonia=location.tags.includes("patagonia")?"patagonia-provisions":"";el.className=`marker ${location.standard} ${isPatagonia}`;const marker=new mapboxgl.Marker(el).setLngLat([location.lng,location.lat]).setPopup(new mapboxgl.Popup().setHTML(`
           <div class="location-popup flex flex-col ${location.standard} ${isPatagonia}">
+            ${popupImage(location)}
             <div class="location-popup-content">
               <ul class="tags">
                 ${location.tags.map((tag)=>`<li class="tag">${tag.icon}</li>`).join("")}
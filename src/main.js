@@ -132,6 +132,41 @@ const eia = (function() {
     LOADED_STYLES.add(url);
   }
 
+  // builds a Contentful Images API URL, the same way Asset.Image does on the
+  // server
+  function imageUrl(asset, width, height) {
+    const url = new URL(asset.url);
+
+    url.searchParams.set("w", width);
+    url.searchParams.set("fm", "webp");
+
+    if (height) {
+      url.searchParams.set("h", height);
+      url.searchParams.set("fit", "fill");
+    }
+
+    return url.toString();
+  }
+
+  // escapes text for use inside a quoted HTML attribute
+  function escapeAttribute(text) {
+    return String(text ?? "")
+      .replace(/&/g, "&amp;")
+      .replace(/"/g, "&quot;")
+      .replace(/</g, "&lt;")
+      .replace(/>/g, "&gt;");
+  }
+
+  function popupImage(location) {
+    const image = location.heroImage;
+
+    if (!image || !image.contentType.startsWith("image/")) {
+      return "";
+    }
+
+    return `<img class="location-popup-image" src="${escapeAttribute(imageUrl(image, 600, 338))}" alt="${escapeAttribute(image.description)}" loading="lazy">`;
+  }
+
   function addMapLocations() {
     Object.keys(locations).forEach(slug => {
       const location = locations[slug];
@@ -145,6 +180,7 @@ const eia = (function() {
           <div class="location-popup flex flex-col ${
             location.standard
           } ${isPatagonia}">
+            ${popupImage(location)}
             <div class="location-popup-content">
               <ul class="tags">
                 ${location.tags.map(
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"embed"
+)
+
+// web holds the templates and static files, so the binary runs on its own
+// from any directory
+//
+//go:embed templates public
+var web embed.FS